@@ -7,64 +7,103 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"sync/atomic"
 	"syscall"
-	"time"
 
+	"order-service/internal/cache"
 	"order-service/internal/config"
 	"order-service/internal/database"
 	"order-service/internal/handler"
 	"order-service/internal/kafka"
+	"order-service/internal/metrics"
+	"order-service/internal/outbox"
+	"order-service/internal/seed"
 	"order-service/internal/service"
-
-	"github.com/gorilla/mux"
 )
 
 func main() {
-	cfg := config.Load()
-
-	db, err := database.NewPostgres(
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Name,
-		cfg.Database.SSLMode,
-	)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db, err := database.NewPostgres(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
-	consumer := kafka.NewConsumer(cfg)
-	defer consumer.Close()
+	if cfg.Database.AutoMigrate {
+		if err := autoMigrate(context.Background(), db); err != nil {
+			log.Fatalf("Failed to apply migrations: %v", err)
+		}
+	}
 
-	orderService := service.NewOrderService(db, consumer)
+	consumer, err := kafka.NewConsumer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create Kafka consumer: %v", err)
+	}
+	defer consumer.Close()
 
-	apiHandler := handler.NewAPIHandler(orderService)
+	orderCache := cache.NewWithOptions(cache.Options{
+		Capacity: cfg.Cache.Capacity,
+		MaxBytes: cfg.Cache.MaxBytes,
+		TTL:      cfg.Cache.TTL,
+		Policy:   cache.Policy(cfg.Cache.Policy),
+	})
+	orderService := service.NewOrderService(cfg, db, orderCache, consumer)
+
+	healthHandler := handler.NewHealthHandler(map[string]handler.HealthCheck{
+		"database": db.Ping,
+		"kafka":    consumer.HealthCheck,
+		"cache":    orderService.CacheHealthCheck,
+	})
 	webHandler, err := handler.NewWebHandler("./templates")
 	if err != nil {
 		log.Fatalf("Failed to initialize web handler: %v", err)
 	}
 
-	router := mux.NewRouter()
-	api := router.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/order/{order_uid}", apiHandler.GetOrder).Methods("GET")
-	api.HandleFunc("/health", apiHandler.HealthCheck).Methods("GET")
-	router.HandleFunc("/", webHandler.ServeIndex).Methods("GET")
-	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
+	metrics.RegisterDBStats(db.Stats)
+	metrics.RegisterCache(func() metrics.CacheStats {
+		stats := orderService.GetCacheStats()
+		return metrics.CacheStats{
+			Size:        stats.Size,
+			Capacity:    stats.Capacity,
+			Hits:        stats.Hits,
+			Misses:      stats.Misses,
+			Evictions:   stats.Evictions,
+			Insertions:  stats.Insertions,
+			Expirations: stats.Expirations,
+			Bytes:       stats.Bytes,
+		}
+	})
+
+	if cfg.Admin.Token == "" {
+		log.Println("Admin API and order status changes disabled: admin.token is not set")
+	}
+	router := handler.NewRouter(orderService, healthHandler, webHandler, cfg.Admin.Token)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port),
 		Handler:      router,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	orderService.Start(ctx)
+	go orderCache.RunExpiry(ctx, cfg.Cache.ExpiryInterval)
 
 	go func() {
 		log.Printf("Server starting on http://%s:%s", cfg.Server.Host, cfg.Server.Port)
@@ -73,16 +112,27 @@ func main() {
 		}
 	}()
 
+	if cfg.Seed.Enabled {
+		go runSeed(ctx, cfg)
+	}
+
+	if cfg.Outbox.Enabled {
+		relay, err := outbox.NewRelay(cfg, db)
+		if err != nil {
+			log.Fatalf("Failed to create outbox relay: %v", err)
+		}
+		defer relay.Close()
+		go relay.Run(ctx)
+	}
+
+	var current atomic.Pointer[config.Config]
+	current.Store(cfg)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 	go func() {
-		time.Sleep(15 * time.Second)
-		fmt.Println("Sending initial test data...")
-		kafka.InitProducer("kafka:9092")
-		defer kafka.Close()
-
-		if err := kafka.SendTestData(context.Background(), 2); err != nil {
-			fmt.Printf("Failed to send test data: %v\n", err)
-		} else {
-			fmt.Println("Test data sent successfully!")
+		for range reload {
+			reloadConfig(cfg, &current, db, consumer, orderService)
 		}
 	}()
 
@@ -91,7 +141,7 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer shutdownCancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
@@ -99,3 +149,48 @@ func main() {
 	}
 	log.Println("Server exited properly")
 }
+
+// reloadConfig перечитывает конфигурацию по SIGHUP и применяет безопасное
+// подмножество параметров. При ошибке продолжает работать со старой конфигурацией.
+// Параметры, требующие перезапуска, сравниваются с конфигурацией запуска started:
+// они действуют с неё до рестарта. current — последняя применённая конфигурация
+func reloadConfig(started *config.Config, current *atomic.Pointer[config.Config],
+	db *database.Postgres, consumer *kafka.Consumer, orderService *service.OrderService) {
+	log.Println("Reloading configuration...")
+
+	next, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Printf("Configuration reload failed, keeping current configuration: %v", err)
+		return
+	}
+
+	if changed := started.RestartRequired(next); len(changed) > 0 {
+		log.Printf("Changes to %v require a restart and were not applied", changed)
+	}
+	if reflect.DeepEqual(current.Load(), next) {
+		log.Println("Configuration unchanged")
+		return
+	}
+
+	db.Reconfigure(next)
+	consumer.SetHealthLimits(next)
+	orderService.Reconfigure(next)
+	current.Store(next)
+
+	log.Println("Configuration reloaded")
+}
+
+func runSeed(ctx context.Context, cfg *config.Config) {
+	producer, err := kafka.NewProducer(cfg, "")
+	if err != nil {
+		log.Printf("Seed: failed to create producer: %v", err)
+		return
+	}
+	defer producer.Close()
+
+	if err := seed.Run(ctx, cfg, producer); err != nil {
+		log.Printf("Seed: failed to publish orders: %v", err)
+		return
+	}
+	log.Println("Seed: orders published")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"order-service/internal/config"
+	"order-service/internal/database"
+	"order-service/migrations"
+)
+
+const migrateUsage = "usage: order-service migrate up | down [steps] | status [config flags]"
+
+// runMigrate выполняет подкоманду migrate: up применяет все новые миграции,
+// down откатывает последние steps (по умолчанию одну), status печатает состояние
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "up", "down", "status":
+	default:
+		return fmt.Errorf("unknown migrate command %q; %s", command, migrateUsage)
+	}
+
+	steps := 1
+	if command == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if n <= 0 {
+				return errors.New("steps must be positive")
+			}
+			steps, args = n, args[1:]
+		}
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	list, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewPostgres(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		n, err := db.MigrateUp(ctx, list)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", n)
+	case "down":
+		n, err := db.MigrateDown(ctx, list, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migration(s)\n", n)
+	case "status":
+		states, err := db.MigrationStatus(ctx, list)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range states {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// autoMigrate применяет миграции при старте, если включён database.auto_migrate
+func autoMigrate(ctx context.Context, db *database.Postgres) error {
+	list, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	n, err := db.MigrateUp(ctx, list)
+	if err != nil {
+		return err
+	}
+	log.Printf("Database schema is up to date, %d migration(s) applied", n)
+	return nil
+}
@@ -7,31 +7,55 @@ import (
 	"log"
 	"os"
 
+	"order-service/internal/config"
 	"order-service/internal/kafka"
+	"order-service/internal/seed"
 )
 
 func main() {
-	var n int
+	var (
+		n        int
+		fixtures string
+	)
 	flag.IntVar(&n, "n", 3, "number of messages to send")
+	flag.StringVar(&fixtures, "fixtures", "", "directory with JSON orders to send instead of generated ones")
 	flag.Parse()
 
-	broker := "kafka:9092"
-	if os.Getenv("KAFKA_BROKER") != "" {
-		broker = os.Getenv("KAFKA_BROKER")
-	} else {
+	// Брокеры, TLS и SASL берутся из тех же переменных окружения, что и у сервиса
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatal("Error:", err)
+	}
+	if os.Getenv("KAFKA_BROKER") == "" && os.Getenv("KAFKA_BROKERS") == "" {
 		if _, err := os.Stat("/.dockerenv"); os.IsNotExist(err) {
-			broker = "localhost:9093"
+			cfg.Kafka.Brokers = []string{"localhost:9093"}
 		}
 	}
 
-	fmt.Printf("Connecting to Kafka broker: %s\n", broker)
-	kafka.InitProducer(broker)
-	defer kafka.Close()
-
-	fmt.Printf("Sending %d messages...\n", n)
-	err := kafka.SendTestData(context.Background(), n)
+	fmt.Printf("Connecting to Kafka brokers: %v\n", cfg.Kafka.Brokers)
+	producer, err := kafka.NewProducer(cfg, "")
 	if err != nil {
 		log.Fatal("Error:", err)
 	}
+	defer producer.Close()
+
+	if fixtures != "" {
+		orders, err := seed.LoadFixtures(fixtures)
+		if err != nil {
+			log.Fatal("Error:", err)
+		}
+
+		fmt.Printf("Sending %d orders from %s...\n", len(orders), fixtures)
+		for _, order := range orders {
+			if err := producer.SendOrder(context.Background(), order); err != nil {
+				log.Fatal("Error:", err)
+			}
+		}
+	} else {
+		fmt.Printf("Sending %d messages...\n", n)
+		if err := producer.SendTestData(context.Background(), n); err != nil {
+			log.Fatal("Error:", err)
+		}
+	}
 	fmt.Println("Done! Check http://localhost:8080")
 }
@@ -1,15 +1,45 @@
 package cache
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"order-service/internal/model"
 )
 
+// Policy определяет, какой заказ вытесняется первым при нехватке места
+type Policy string
+
+const (
+	// PolicyLRU вытесняет заказ, который дольше всех не читали
+	PolicyLRU Policy = "lru"
+	// PolicyFIFO вытесняет заказ, который раньше всех попал в кэш; чтение
+	// не меняет порядок и обходится без перестройки списка
+	PolicyFIFO Policy = "fifo"
+)
+
+func (p Policy) IsValid() bool {
+	return p == PolicyLRU || p == PolicyFIFO
+}
+
+type Options struct {
+	// Capacity — максимум заказов в кэше
+	Capacity int
+	// MaxBytes ограничивает оценочный объём заказов (см. EstimateSize); 0 — без ограничения
+	MaxBytes int64
+	// TTL — время жизни записи с момента записи в кэш; 0 — записи не устаревают
+	TTL    time.Duration
+	Policy Policy
+}
+
 type cacheNode struct {
-	order model.Order
-	next  *cacheNode
-	prev  *cacheNode
+	order     model.Order
+	size      int64
+	expiresAt time.Time
+	next      *cacheNode
+	prev      *cacheNode
 }
 
 type Cache struct {
@@ -17,64 +47,173 @@ type Cache struct {
 	orders   map[string]*cacheNode
 	capacity int
 	count    int
+	bytes    int64
+	maxBytes int64
+	ttl      time.Duration
+	policy   Policy
 	head     *cacheNode
 	tail     *cacheNode
+	now      func() time.Time
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	insertions  atomic.Uint64
+	expirations atomic.Uint64
+
+	// lastLoad — последняя загрузка кэша из БД, защищена mu
+	lastLoad LoadStats
+	// touched — заказы, изменённые через Set или Delete во время Rebuild;
+	// nil, пока Rebuild не выполняется. Защищено mu
+	touched map[string]struct{}
 }
 
+type Stats struct {
+	Size        int     `json:"size"`
+	Capacity    int     `json:"capacity"`
+	Bytes       int64   `json:"bytes"`
+	MaxBytes    int64   `json:"max_bytes,omitempty"`
+	TTLSeconds  float64 `json:"ttl_seconds,omitempty"`
+	Policy      Policy  `json:"policy"`
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	Evictions   uint64  `json:"evictions"`
+	Insertions  uint64  `json:"insertions"`
+	Expirations uint64  `json:"expirations"`
+	// LastLoad пуст, пока кэш ни разу не загружался из БД
+	LastLoad *LoadStats `json:"last_load,omitempty"`
+}
+
+// LoadStats описывает загрузку кэша из БД при старте или по запросу
+type LoadStats struct {
+	At       time.Time     `json:"at"`
+	Orders   int           `json:"orders"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+}
+
+// New создаёт LRU-кэш на capacity заказов без TTL и ограничения по объёму
 func New(capacity int) *Cache {
-	if capacity <= 0 {
-		capacity = 1000 // default capacity
+	return NewWithOptions(Options{Capacity: capacity})
+}
+
+func NewWithOptions(opts Options) *Cache {
+	if opts.Capacity <= 0 {
+		opts.Capacity = 1000 // default capacity
+	}
+	if !opts.Policy.IsValid() {
+		opts.Policy = PolicyLRU
 	}
 	return &Cache{
 		orders:   make(map[string]*cacheNode),
-		capacity: capacity,
+		capacity: opts.Capacity,
+		maxBytes: opts.MaxBytes,
+		ttl:      opts.TTL,
+		policy:   opts.Policy,
+		now:      time.Now,
 	}
 }
 
+// Set кладёт заказ в кэш. Более свежий снимок уже закэшированного заказа (см.
+// model.Order.NewerThan) он не заменяет, чтобы запоздавшая запись не вернула
+// устаревшие данные
 func (c *Cache) Set(order model.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if node, exists := c.orders[order.OrderUID]; exists {
-		node.order = order
-		c.moveToFront(node)
+	if node, exists := c.orders[order.OrderUID]; exists && !c.expired(node) && node.order.NewerThan(&order) {
+		c.touch(order.OrderUID)
 		return
 	}
+	c.set(order)
+}
+
+// Add кладёт заказ, только если его ещё нет в кэше, и сообщает, добавлен ли он.
+// Используется при чтении из БД в обход кэша: прочитанный снимок мог устареть,
+// пока шёл запрос, и не должен перезаписать то, что успели закэшировать писатели
+func (c *Cache) Add(order model.Order) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	node := &cacheNode{
-		order: order,
+	if node, exists := c.orders[order.OrderUID]; exists && !c.expired(node) {
+		return false
 	}
+	c.set(order)
+	return true
+}
 
-	if c.count >= c.capacity {
-		c.removeOldest()
+func (c *Cache) set(order model.Order) {
+	c.touch(order.OrderUID)
+	size := EstimateSize(&order)
+	node, exists := c.orders[order.OrderUID]
+	if !c.fits(size) {
+		// Заказ больше всего кэша: не храним, но и старую версию не оставляем
+		if exists {
+			c.remove(node)
+		}
+		return
 	}
 
+	if exists {
+		c.bytes += size - node.size
+		node.order = order
+		node.size = size
+		node.expiresAt = c.expiry()
+		if c.policy == PolicyLRU {
+			c.moveToFront(node)
+		}
+		c.evict()
+		return
+	}
+
+	node = &cacheNode{
+		order:     order,
+		size:      size,
+		expiresAt: c.expiry(),
+	}
 	c.orders[order.OrderUID] = node
 	c.addToFront(node)
 	c.count++
+	c.bytes += size
+	c.insertions.Add(1)
+	c.evict()
 }
 
+// Get возвращает заказ и при LRU переносит его в начало списка. Перенос идёт
+// синхронно под блокировкой на запись: отложенное обновление в горутине могло
+// вернуть в список уже вытесненный узел
 func (c *Cache) Get(orderUID string) (model.Order, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	node, exists := c.orders[orderUID]
+	if exists && c.expired(node) {
+		c.remove(node)
+		c.expirations.Add(1)
+		exists = false
+	}
 	if !exists {
+		c.misses.Add(1)
 		return model.Order{}, false
 	}
-
-	go c.updateAccess(node)
+	c.hits.Add(1)
+	if c.policy == PolicyLRU {
+		c.moveToFront(node)
+	}
 
 	return node.order, true
 }
 
-func (c *Cache) updateAccess(node *cacheNode) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Peek возвращает заказ без обновления LRU-порядка и статистики попаданий
+func (c *Cache) Peek(orderUID string) (model.Order, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	if _, exists := c.orders[node.order.OrderUID]; exists {
-		c.moveToFront(node)
+	node, exists := c.orders[orderUID]
+	if !exists || c.expired(node) {
+		return model.Order{}, false
 	}
+	return node.order, true
 }
 
 func (c *Cache) GetAll() []model.Order {
@@ -83,7 +222,9 @@ func (c *Cache) GetAll() []model.Order {
 
 	orders := make([]model.Order, 0, len(c.orders))
 	for _, node := range c.orders {
-		orders = append(orders, node.order)
+		if !c.expired(node) {
+			orders = append(orders, node.order)
+		}
 	}
 	return orders
 }
@@ -92,20 +233,238 @@ func (c *Cache) LoadFromOrders(orders []model.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.orders = make(map[string]*cacheNode)
-	c.head = nil
-	c.tail = nil
-	c.count = 0
+	c.reset()
+	c.appendOrders(orders)
+}
+
+// Append добавляет заказы в конец списка (первыми на вытеснение), пока есть
+// свободное место, и возвращает число добавленных. Используется для
+// потоковой загрузки кэша от самых свежих заказов к более старым
+func (c *Cache) Append(orders []model.Order) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.appendOrders(orders)
+}
+
+func (c *Cache) appendOrders(orders []model.Order) int {
+	added := 0
+	for _, order := range orders {
+		if c.count >= c.capacity {
+			break
+		}
+		if _, exists := c.orders[order.OrderUID]; exists {
+			continue
+		}
+
+		size := EstimateSize(&order)
+		if !c.fits(size) {
+			continue
+		}
+		if c.maxBytes > 0 && c.bytes+size > c.maxBytes {
+			break
+		}
 
-	for i := 0; i < len(orders) && i < c.capacity; i++ {
-		order := orders[i]
 		node := &cacheNode{
-			order: order,
+			order:     order,
+			size:      size,
+			expiresAt: c.expiry(),
 		}
 		c.orders[order.OrderUID] = node
-		c.addToFront(node)
+		c.addToBack(node)
 		c.count++
+		c.bytes += size
+		added++
+	}
+	c.insertions.Add(uint64(added))
+	return added
+}
+
+// Delete удаляет заказ из кэша и сообщает, был ли он там
+func (c *Cache) Delete(orderUID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.touch(orderUID)
+	node, exists := c.orders[orderUID]
+	if !exists {
+		return false
 	}
+	c.remove(node)
+	return true
+}
+
+// SetStatus меняет статус закэшированного заказа на месте, если statusVersion
+// новее закэшированной, и сообщает, был ли заказ в кэше. В отличие от Peek и
+// Set не может вернуть в кэш версию заказа, которую между ними перезаписал
+// другой поток
+func (c *Cache) SetStatus(orderUID string, status model.OrderStatus, statusVersion int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node, exists := c.orders[orderUID]
+	if !exists || c.expired(node) {
+		return false
+	}
+	c.touch(orderUID)
+	if node.order.StatusVersion < statusVersion {
+		node.order.Status = status
+		node.order.StatusVersion = statusVersion
+	}
+	return true
+}
+
+// Rebuild собирает новое содержимое кэша через fill и одним шагом подменяет
+// им текущее, поэтому во время загрузки кэш продолжает отвечать. fill передаёт
+// заказы в add от самых свежих к более старым; add возвращает, сколько из них
+// поместилось. Заказы, изменённые через Set или Delete, пока работал fill,
+// берутся из текущего кэша, а не из загруженных (возможно, устаревших) данных.
+// Если fill вернул ошибку, кэш не меняется. Параллельно не вызывается
+func (c *Cache) Rebuild(fill func(add func([]model.Order) int) error) (int, error) {
+	c.mu.Lock()
+	c.touched = make(map[string]struct{})
+	capacity := c.capacity
+	c.mu.Unlock()
+
+	var (
+		staged []model.Order
+		bytes  int64
+		seen   = make(map[string]struct{})
+	)
+	err := fill(func(orders []model.Order) int {
+		added := 0
+		for _, order := range orders {
+			if len(staged) >= capacity {
+				break
+			}
+			if _, ok := seen[order.OrderUID]; ok {
+				continue
+			}
+			size := EstimateSize(&order)
+			if !c.fits(size) {
+				continue
+			}
+			if c.maxBytes > 0 && bytes+size > c.maxBytes {
+				break
+			}
+			seen[order.OrderUID] = struct{}{}
+			staged = append(staged, order)
+			bytes += size
+			added++
+		}
+		return added
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	touched := c.touched
+	c.touched = nil
+	if err != nil {
+		return 0, err
+	}
+
+	// Изменённые во время загрузки записи переносятся в новый список как есть
+	var kept []*cacheNode
+	for node := c.head; node != nil; node = node.next {
+		if _, ok := touched[node.order.OrderUID]; ok {
+			kept = append(kept, node)
+		}
+	}
+
+	c.reset()
+	for _, node := range kept {
+		node.prev, node.next = nil, nil
+		c.orders[node.order.OrderUID] = node
+		c.addToBack(node)
+		c.count++
+		c.bytes += node.size
+	}
+
+	loaded := 0
+	for _, order := range staged {
+		if _, ok := touched[order.OrderUID]; ok {
+			continue
+		}
+		if c.count >= c.capacity {
+			break
+		}
+		size := EstimateSize(&order)
+		if c.maxBytes > 0 && c.bytes+size > c.maxBytes {
+			break
+		}
+		node := &cacheNode{order: order, size: size, expiresAt: c.expiry()}
+		c.orders[order.OrderUID] = node
+		c.addToBack(node)
+		c.count++
+		c.bytes += size
+		loaded++
+	}
+	c.insertions.Add(uint64(loaded))
+	c.evict()
+	return loaded, nil
+}
+
+// RemoveExpired удаляет все устаревшие записи и возвращает их число
+func (c *Cache) RemoveExpired() int {
+	if c.ttl <= 0 {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for node := c.head; node != nil; {
+		next := node.next
+		if c.expired(node) {
+			c.remove(node)
+			removed++
+		}
+		node = next
+	}
+	c.expirations.Add(uint64(removed))
+	return removed
+}
+
+// RunExpiry удаляет устаревшие записи каждые interval, пока не отменён ctx.
+// Без фоновой очистки устаревшие записи удаляются только при чтении
+func (c *Cache) RunExpiry(ctx context.Context, interval time.Duration) {
+	if c.ttl <= 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.RemoveExpired()
+		}
+	}
+}
+
+// RecordLoad запоминает итог загрузки кэша из БД для Stats
+func (c *Cache) RecordLoad(orders int, took time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastLoad = LoadStats{
+		At:       time.Now(),
+		Orders:   orders,
+		Duration: took,
+		Seconds:  took.Seconds(),
+	}
+}
+
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reset()
 }
 
 func (c *Cache) Size() int {
@@ -115,9 +474,86 @@ func (c *Cache) Size() int {
 }
 
 func (c *Cache) Capacity() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.capacity
 }
 
+// Resize меняет ёмкость кэша, вытесняя самые старые записи при уменьшении
+func (c *Cache) Resize(capacity int) {
+	if capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	c.evict()
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := Stats{
+		Size:        c.count,
+		Capacity:    c.capacity,
+		Bytes:       c.bytes,
+		MaxBytes:    c.maxBytes,
+		TTLSeconds:  c.ttl.Seconds(),
+		Policy:      c.policy,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Insertions:  c.insertions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+	if !c.lastLoad.At.IsZero() {
+		load := c.lastLoad
+		stats.LastLoad = &load
+	}
+	return stats
+}
+
+func (c *Cache) expiry() time.Time {
+	if c.ttl <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(c.ttl)
+}
+
+func (c *Cache) expired(node *cacheNode) bool {
+	return !node.expiresAt.IsZero() && !c.now().Before(node.expiresAt)
+}
+
+// fits сообщает, может ли заказ такого размера вообще поместиться в кэш
+func (c *Cache) fits(size int64) bool {
+	return c.maxBytes <= 0 || size <= c.maxBytes
+}
+
+// evict вытесняет записи с конца списка, пока кэш не уложится в лимиты
+func (c *Cache) evict() {
+	for c.count > c.capacity || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeOldest()
+	}
+}
+
+// touch отмечает заказ изменённым, если выполняется Rebuild
+func (c *Cache) touch(orderUID string) {
+	if c.touched != nil {
+		c.touched[orderUID] = struct{}{}
+	}
+}
+
+func (c *Cache) reset() {
+	c.orders = make(map[string]*cacheNode)
+	c.head = nil
+	c.tail = nil
+	c.count = 0
+	c.bytes = 0
+}
+
 func (c *Cache) addToFront(node *cacheNode) {
 	if c.head == nil {
 		c.head = node
@@ -130,31 +566,48 @@ func (c *Cache) addToFront(node *cacheNode) {
 	c.head = node
 }
 
+func (c *Cache) addToBack(node *cacheNode) {
+	if c.tail == nil {
+		c.head = node
+		c.tail = node
+		return
+	}
+
+	node.prev = c.tail
+	c.tail.next = node
+	c.tail = node
+}
+
 func (c *Cache) moveToFront(node *cacheNode) {
 	if node == c.head {
 		return
 	}
 
+	c.unlink(node)
+	c.addToFront(node)
+}
+
+// unlink вынимает узел из списка, не трогая map и счётчики
+func (c *Cache) unlink(node *cacheNode) {
 	if node.prev != nil {
 		node.prev.next = node.next
+	} else {
+		c.head = node.next
 	}
 	if node.next != nil {
 		node.next.prev = node.prev
-	}
-	if node == c.tail {
+	} else {
 		c.tail = node.prev
 	}
-
-	node.next = c.head
 	node.prev = nil
-	if c.head != nil {
-		c.head.prev = node
-	}
-	c.head = node
+	node.next = nil
+}
 
-	if c.tail == nil {
-		c.tail = node
-	}
+func (c *Cache) remove(node *cacheNode) {
+	delete(c.orders, node.order.OrderUID)
+	c.unlink(node)
+	c.count--
+	c.bytes -= node.size
 }
 
 func (c *Cache) removeOldest() {
@@ -162,13 +615,6 @@ func (c *Cache) removeOldest() {
 		return
 	}
 
-	delete(c.orders, c.tail.order.OrderUID)
-
-	if c.tail.prev != nil {
-		c.tail.prev.next = nil
-	} else {
-		c.head = nil
-	}
-	c.tail = c.tail.prev
-	c.count--
+	c.remove(c.tail)
+	c.evictions.Add(1)
 }
@@ -0,0 +1,416 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func order(uid string) model.Order {
+	return model.Order{OrderUID: uid}
+}
+
+// keys возвращает order_uid от самого свежего к самому старому и проверяет
+// согласованность двусвязного списка
+func keys(t *testing.T, c *Cache) []string {
+	t.Helper()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var uids []string
+	var prev *cacheNode
+	for node := c.head; node != nil; node = node.next {
+		require.Same(t, prev, node.prev, "broken prev link at %s", node.order.OrderUID)
+		require.Same(t, node, c.orders[node.order.OrderUID], "list node is not indexed")
+		uids = append(uids, node.order.OrderUID)
+		prev = node
+	}
+	require.Same(t, prev, c.tail)
+	require.Len(t, uids, c.count)
+	require.Len(t, c.orders, c.count)
+	return uids
+}
+
+func TestSetAndGet(t *testing.T) {
+	c := New(2)
+	c.Set(order("a"))
+
+	got, ok := c.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, "a", got.OrderUID)
+
+	_, ok = c.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(3)
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Set(order("c"))
+	assert.Equal(t, []string{"c", "b", "a"}, keys(t, c))
+
+	c.Get("a")
+	assert.Equal(t, []string{"a", "c", "b"}, keys(t, c))
+
+	c.Set(order("d"))
+	assert.Equal(t, []string{"d", "a", "c"}, keys(t, c))
+	_, ok := c.Peek("b")
+	assert.False(t, ok, "least recently used order must be evicted")
+	assert.Equal(t, uint64(1), c.Stats().Evictions)
+}
+
+func TestSetExistingMovesToFront(t *testing.T) {
+	c := New(3)
+	c.Set(order("a"))
+	c.Set(order("b"))
+
+	updated := order("a")
+	updated.TrackNumber = "NEW"
+	c.Set(updated)
+
+	assert.Equal(t, []string{"a", "b"}, keys(t, c))
+	got, _ := c.Peek("a")
+	assert.Equal(t, "NEW", got.TrackNumber)
+}
+
+func TestSetKeepsNewerVersion(t *testing.T) {
+	c := New(3)
+	newer := order("a")
+	newer.Version = 2
+	newer.TrackNumber = "NEW"
+	c.Set(newer)
+
+	stale := order("a")
+	stale.Version = 1
+	c.Set(stale)
+	got, _ := c.Peek("a")
+	assert.Equal(t, int64(2), got.Version)
+	assert.Equal(t, "NEW", got.TrackNumber)
+
+	newer.Version = 3
+	c.Set(newer)
+	got, _ = c.Peek("a")
+	assert.Equal(t, int64(3), got.Version)
+}
+
+func TestSetKeepsNewerStatus(t *testing.T) {
+	c := New(3)
+	paid := order("a")
+	paid.Status = model.StatusPaid
+	paid.StatusVersion = 1
+	c.Set(paid)
+
+	c.Set(order("a"))
+	got, _ := c.Peek("a")
+	assert.Equal(t, model.StatusPaid, got.Status, "snapshot read before the status change")
+}
+
+func TestAddOnlyWhenAbsent(t *testing.T) {
+	c := New(3)
+	cached := order("a")
+	cached.Version = 2
+	c.Set(cached)
+
+	snapshot := order("a")
+	snapshot.Version = 3
+	assert.False(t, c.Add(snapshot), "read-through must not replace a cached order")
+	got, _ := c.Peek("a")
+	assert.Equal(t, int64(2), got.Version)
+
+	assert.True(t, c.Add(order("b")))
+	_, ok := c.Peek("b")
+	assert.True(t, ok)
+}
+
+func TestPeekDoesNotTouchOrderOrStats(t *testing.T) {
+	c := New(2)
+	c.Set(order("a"))
+	c.Set(order("b"))
+
+	_, ok := c.Peek("a")
+	require.True(t, ok)
+	assert.Equal(t, []string{"b", "a"}, keys(t, c))
+	assert.Zero(t, c.Stats().Hits)
+}
+
+func TestAppendFillsFromTheBack(t *testing.T) {
+	c := New(3)
+	c.Set(order("a"))
+
+	added := c.Append([]model.Order{order("b"), order("a"), order("c"), order("d")})
+	assert.Equal(t, 2, added, "existing orders are skipped and capacity is not exceeded")
+	assert.Equal(t, []string{"a", "b", "c"}, keys(t, c))
+	assert.Zero(t, c.Stats().Evictions)
+}
+
+func TestSetStatus(t *testing.T) {
+	c := New(3)
+	c.Set(order("a"))
+	c.Set(order("b"))
+
+	assert.True(t, c.SetStatus("a", model.StatusPaid, 1))
+	got, _ := c.Peek("a")
+	assert.Equal(t, model.StatusPaid, got.Status)
+	assert.Equal(t, int64(1), got.StatusVersion)
+	assert.Equal(t, []string{"b", "a"}, keys(t, c), "status update does not count as a read")
+
+	assert.True(t, c.SetStatus("a", model.StatusCreated, 1), "stale status update")
+	got, _ = c.Peek("a")
+	assert.Equal(t, model.StatusPaid, got.Status)
+
+	assert.False(t, c.SetStatus("missing", model.StatusPaid, 1))
+	_, ok := c.Peek("missing")
+	assert.False(t, ok, "status update must not create an entry")
+}
+
+func TestRebuildSwapsContents(t *testing.T) {
+	c := New(3)
+	c.Set(order("old"))
+
+	loaded, err := c.Rebuild(func(add func([]model.Order) int) error {
+		assert.Equal(t, []string{"old"}, keys(t, c), "cache keeps serving while loading")
+		assert.Equal(t, 2, add([]model.Order{order("a"), order("b")}))
+		assert.Equal(t, 1, add([]model.Order{order("a"), order("c"), order("d")}))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, loaded)
+	assert.Equal(t, []string{"a", "b", "c"}, keys(t, c))
+}
+
+func TestRebuildKeepsCacheOnError(t *testing.T) {
+	c := New(3)
+	c.Set(order("old"))
+
+	_, err := c.Rebuild(func(add func([]model.Order) int) error {
+		add([]model.Order{order("a")})
+		return errors.New("db down")
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{"old"}, keys(t, c))
+}
+
+func TestRebuildPrefersConcurrentWrites(t *testing.T) {
+	c := New(4)
+	c.Set(order("deleted"))
+
+	fresh := order("a")
+	fresh.TrackNumber = "FRESH"
+	_, err := c.Rebuild(func(add func([]model.Order) int) error {
+		stale := order("a")
+		stale.TrackNumber = "STALE"
+		add([]model.Order{stale, order("b"), order("deleted")})
+
+		// Запись и удаление во время загрузки новее прочитанных данных
+		c.Set(fresh)
+		c.Set(order("new"))
+		c.Delete("deleted")
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"new", "a", "b"}, keys(t, c))
+	got, _ := c.Peek("a")
+	assert.Equal(t, "FRESH", got.TrackNumber)
+
+	c.Set(order("after"))
+	assert.Nil(t, c.touched, "writes after the rebuild are not tracked")
+}
+
+func TestResize(t *testing.T) {
+	c := New(4)
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		c.Set(order(uid))
+	}
+
+	c.Resize(2)
+	assert.Equal(t, []string{"d", "c"}, keys(t, c))
+	assert.Equal(t, 2, c.Capacity())
+
+	c.Resize(0)
+	assert.Equal(t, 2, c.Capacity(), "non-positive capacity is ignored")
+}
+
+func TestStats(t *testing.T) {
+	c := New(1)
+	c.Set(order("a"))
+	c.Get("a")
+	c.Get("b")
+	c.Set(order("b"))
+	c.Set(order("b"))
+
+	b := order("b")
+	assert.Equal(t, Stats{
+		Size: 1, Capacity: 1, Bytes: EstimateSize(&b), Policy: PolicyLRU,
+		Hits: 1, Misses: 1, Evictions: 1, Insertions: 2,
+	}, c.Stats())
+
+	c.RecordLoad(1, 1500*time.Millisecond)
+	stats := c.Stats()
+	require.NotNil(t, stats.LastLoad)
+	assert.Equal(t, 1, stats.LastLoad.Orders)
+	assert.Equal(t, 1.5, stats.LastLoad.Seconds)
+}
+
+func TestDelete(t *testing.T) {
+	c := New(3)
+	for _, uid := range []string{"a", "b", "c"} {
+		c.Set(order(uid))
+	}
+
+	for _, uid := range []string{"b", "c", "a"} {
+		assert.True(t, c.Delete(uid))
+		_, ok := c.Peek(uid)
+		assert.False(t, ok)
+		keys(t, c)
+	}
+	assert.False(t, c.Delete("a"))
+	assert.Zero(t, c.Size())
+
+	c.Set(order("d"))
+	assert.Equal(t, []string{"d"}, keys(t, c))
+}
+
+// TestConcurrentAccess имеет смысл запускать с -race
+func TestConcurrentAccess(t *testing.T) {
+	c := New(50)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				uid := fmt.Sprintf("order-%d", (w*31+i)%120)
+				switch i % 5 {
+				case 0, 1:
+					c.Set(order(uid))
+				case 2:
+					c.Get(uid)
+				case 3:
+					c.Peek(uid)
+				case 4:
+					c.Append([]model.Order{order(uid)})
+				}
+				if i%100 == 0 {
+					c.Resize(40 + w)
+					c.Stats()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	uids := keys(t, c)
+	assert.LessOrEqual(t, len(uids), c.Capacity())
+}
+
+// fakeClock подменяет c.now, чтобы проверять TTL без ожидания
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time          { return f.t }
+func (f *fakeClock) advance(d time.Duration) { f.t = f.t.Add(d) }
+func newWithClock(opts Options) (*Cache, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewWithOptions(opts)
+	c.now = clock.now
+	return c, clock
+}
+
+func TestTTLExpiresLazily(t *testing.T) {
+	c, clock := newWithClock(Options{Capacity: 10, TTL: time.Minute})
+	c.Set(order("a"))
+	clock.advance(30 * time.Second)
+	c.Set(order("b"))
+
+	clock.advance(30 * time.Second)
+	_, ok := c.Peek("a")
+	assert.False(t, ok, "expired entry must not be visible")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+
+	stats := c.Stats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.Equal(t, uint64(1), stats.Misses)
+
+	c.Set(order("b"))
+	clock.advance(59 * time.Second)
+	_, ok = c.Get("b")
+	assert.True(t, ok, "Set must refresh the TTL")
+}
+
+func TestRemoveExpired(t *testing.T) {
+	c, clock := newWithClock(Options{Capacity: 10, TTL: time.Minute})
+	c.Set(order("a"))
+	clock.advance(30 * time.Second)
+	c.Set(order("b"))
+	clock.advance(40 * time.Second)
+	c.Set(order("c"))
+
+	assert.Equal(t, 1, c.RemoveExpired(), "only a is older than a minute")
+	assert.Equal(t, []string{"c", "b"}, keys(t, c))
+	assert.Equal(t, uint64(1), c.Stats().Expirations)
+
+	c.Append([]model.Order{order("d")})
+	clock.advance(time.Minute)
+	assert.Equal(t, 3, c.RemoveExpired())
+	assert.Empty(t, keys(t, c))
+	assert.Zero(t, c.Stats().Bytes)
+}
+
+func TestMaxBytes(t *testing.T) {
+	small := order("small")
+	size := EstimateSize(&small)
+	c := NewWithOptions(Options{Capacity: 100, MaxBytes: 3 * size})
+
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		c.Set(order(uid))
+	}
+	assert.Equal(t, []string{"d", "c", "b"}, keys(t, c), "byte limit evicts before capacity")
+	assert.LessOrEqual(t, c.Stats().Bytes, 3*size)
+
+	big := order("big")
+	for i := 0; i < 20; i++ {
+		big.Items = append(big.Items, model.Item{ChrtID: i, Name: "Mascaras"})
+	}
+	require.Greater(t, EstimateSize(&big), 3*size)
+	c.Set(big)
+	_, ok := c.Peek("big")
+	assert.False(t, ok, "order larger than the whole cache is not stored")
+	assert.Equal(t, []string{"d", "c", "b"}, keys(t, c))
+
+	c.Set(order("big"))
+	c.Set(big)
+	_, ok = c.Peek("big")
+	assert.False(t, ok, "oversized update must drop the stale copy")
+	keys(t, c)
+}
+
+func TestEstimateSizeGrowsWithItems(t *testing.T) {
+	o := order("a")
+	base := EstimateSize(&o)
+	o.Items = []model.Item{{Name: "Mascaras", Brand: "Vivienne Sabo"}}
+	assert.Greater(t, EstimateSize(&o), base)
+}
+
+func TestFIFOPolicy(t *testing.T) {
+	c := NewWithOptions(Options{Capacity: 2, Policy: PolicyFIFO})
+	c.Set(order("a"))
+	c.Set(order("b"))
+	c.Get("a")
+	c.Set(order("c"))
+
+	assert.Equal(t, []string{"c", "b"}, keys(t, c), "reads do not protect from eviction")
+	assert.Equal(t, PolicyFIFO, c.Stats().Policy)
+}
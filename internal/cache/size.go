@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"unsafe"
+
+	"order-service/internal/model"
+)
+
+// mapEntryOverhead — примерная цена записи в map[string]*cacheNode сверх
+// самого ключа: заголовок строки, указатель и служебные байты бакета
+const mapEntryOverhead = 48
+
+// EstimateSize оценивает, сколько байт занимает заказ в кэше: узел списка
+// со структурой заказа, запись в индексе, содержимое строк и массив товаров.
+// Оценка приблизительная, но растёт вместе с реальным размером заказа
+func EstimateSize(order *model.Order) int64 {
+	size := int64(unsafe.Sizeof(cacheNode{})) + mapEntryOverhead
+	size += totalLen(order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
+		order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey,
+		order.OofShard, string(order.Status))
+
+	d := &order.Delivery
+	size += totalLen(d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email)
+
+	p := &order.Payment
+	size += totalLen(p.Transaction, p.RequestID, p.Currency, p.Provider, p.Bank)
+
+	size += int64(cap(order.Items)) * int64(unsafe.Sizeof(model.Item{}))
+	for i := range order.Items {
+		item := &order.Items[i]
+		size += totalLen(item.TrackNumber, item.Rid, item.Name, item.Size, item.Brand)
+	}
+	return size
+}
+
+func totalLen(values ...string) int64 {
+	var n int64
+	for _, v := range values {
+		n += int64(len(v))
+	}
+	return n
+}
@@ -1,49 +1,409 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 type Config struct {
 	Server struct {
-		Host string
-		Port string
-	}
+		Host            string        `yaml:"host"`
+		Port            string        `yaml:"port"`
+		ReadTimeout     time.Duration `yaml:"read_timeout"`
+		WriteTimeout    time.Duration `yaml:"write_timeout"`
+		IdleTimeout     time.Duration `yaml:"idle_timeout"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	} `yaml:"server"`
 	Database struct {
-		Host     string
-		Port     string
-		User     string
-		Password string
-		Name     string
-		SSLMode  string
-	}
+		Host     string `yaml:"host"`
+		Port     string `yaml:"port"`
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+		Name     string `yaml:"name"`
+		SSLMode  string `yaml:"sslmode"`
+
+		ConnectTimeout time.Duration `yaml:"connect_timeout"`
+		QueryTimeout   time.Duration `yaml:"query_timeout"`
+		// AutoMigrate применяет миграции при старте сервиса
+		AutoMigrate bool `yaml:"auto_migrate"`
+
+		MaxOpenConns    int           `yaml:"max_open_conns"`
+		MaxIdleConns    int           `yaml:"max_idle_conns"`
+		ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+
+		RetryMaxAttempts    int           `yaml:"retry_max_attempts"`
+		RetryInitialBackoff time.Duration `yaml:"retry_initial_backoff"`
+		RetryMaxBackoff     time.Duration `yaml:"retry_max_backoff"`
+		BreakerThreshold    int           `yaml:"breaker_threshold"`
+		BreakerOpenTimeout  time.Duration `yaml:"breaker_open_timeout"`
+	} `yaml:"database"`
 	Kafka struct {
-		Brokers []string
-		Topic   string
-		GroupID string
-	}
+		Brokers  []string `yaml:"brokers"`
+		Topic    string   `yaml:"topic"`
+		GroupID  string   `yaml:"group_id"`
+		DLQTopic string   `yaml:"dlq_topic"`
+
+		MinBytes int `yaml:"min_bytes"`
+		MaxBytes int `yaml:"max_bytes"`
+
+		CommitInterval  time.Duration `yaml:"commit_interval"`
+		CommitBatchSize int           `yaml:"commit_batch_size"`
+
+		MaxLag       int64         `yaml:"max_lag"`
+		StuckTimeout time.Duration `yaml:"stuck_timeout"`
+
+		TLS struct {
+			Enabled            bool   `yaml:"enabled"`
+			CAFile             string `yaml:"ca_file"`
+			CertFile           string `yaml:"cert_file"`
+			KeyFile            string `yaml:"key_file"`
+			ServerName         string `yaml:"server_name"`
+			InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+		} `yaml:"tls"`
+		SASL struct {
+			// Mechanism: PLAIN, SCRAM-SHA-256 или SCRAM-SHA-512; пусто — без SASL
+			Mechanism string `yaml:"mechanism"`
+			Username  string `yaml:"username"`
+			Password  string `yaml:"password"`
+		} `yaml:"sasl"`
+	} `yaml:"kafka"`
+	Producer struct {
+		// Balancer: hash, murmur2, least_bytes, round_robin
+		Balancer string `yaml:"balancer"`
+		// Compression: none, gzip, snappy, lz4, zstd
+		Compression string `yaml:"compression"`
+		// RequiredAcks: -1 — все реплики, 1 — лидер, 0 — без подтверждения.
+		// Продюсеры DLQ и outbox всегда ждут все реплики
+		RequiredAcks           int           `yaml:"required_acks"`
+		BatchSize              int           `yaml:"batch_size"`
+		BatchBytes             int64         `yaml:"batch_bytes"`
+		BatchTimeout           time.Duration `yaml:"batch_timeout"`
+		AllowAutoTopicCreation bool          `yaml:"allow_auto_topic_creation"`
+	} `yaml:"producer"`
+	Processing struct {
+		// Workers — число параллельных обработчиков; заказы с одним order_uid
+		// всегда обрабатываются одним воркером по порядку
+		Workers   int `yaml:"workers"`
+		QueueSize int `yaml:"queue_size"`
+		// BatchSize > 1 включает запись заказов пачками через SaveOrders;
+		// воркер ждёт добора пачки не дольше BatchWindow
+		BatchSize   int           `yaml:"batch_size"`
+		BatchWindow time.Duration `yaml:"batch_window"`
+		// RequireVersion отправляет в DLQ заказы без положительной version.
+		// Без него такие заказы перезаписываются в порядке чтения из Kafka
+		RequireVersion bool `yaml:"require_version"`
+	} `yaml:"processing"`
+	Seed struct {
+		// Enabled включает публикацию демонстрационных заказов при старте сервиса
+		Enabled     bool          `yaml:"enabled"`
+		FixturesDir string        `yaml:"fixtures_dir"`
+		Count       int           `yaml:"count"`
+		Delay       time.Duration `yaml:"delay"`
+	} `yaml:"seed"`
+	Outbox struct {
+		// Enabled запускает публикацию событий из outbox; сами события
+		// пишутся всегда и дождутся включения relay
+		Enabled      bool          `yaml:"enabled"`
+		Topic        string        `yaml:"topic"`
+		PollInterval time.Duration `yaml:"poll_interval"`
+		BatchSize    int           `yaml:"batch_size"`
+		Retention    time.Duration `yaml:"retention"`
+	} `yaml:"outbox"`
+	Cache struct {
+		Capacity        int `yaml:"capacity"`
+		WarmUpBatchSize int `yaml:"warmup_batch_size"`
+		// MaxBytes ограничивает оценочный объём заказов в кэше; 0 — только по числу
+		MaxBytes int64 `yaml:"max_bytes"`
+		// TTL — время жизни записи; устаревшие записи удаляются при чтении
+		// и фоново раз в ExpiryInterval. 0 — записи не устаревают
+		TTL            time.Duration `yaml:"ttl"`
+		ExpiryInterval time.Duration `yaml:"expiry_interval"`
+		// Policy: lru или fifo
+		Policy string `yaml:"policy"`
+	} `yaml:"cache"`
+	Admin struct {
+		// Token открывает /api/admin/* по заголовку Authorization: Bearer <token>;
+		// пустой токен отключает административные эндпоинты
+		Token string `yaml:"token"`
+	} `yaml:"admin"`
 }
 
-func Load() *Config {
+func defaults() *Config {
 	var cfg Config
 
-	cfg.Server.Host = getEnv("SERVER_HOST", "0.0.0.0")
-	cfg.Server.Port = getEnv("SERVER_PORT", "8080")
-	cfg.Database.Host = getEnv("DB_HOST", "postgres")
-	cfg.Database.Port = getEnv("DB_PORT", "5432")
-	cfg.Database.User = getEnv("DB_USER", "postgres")
-	cfg.Database.Password = getEnv("DB_PASSWORD", "password")
-	cfg.Database.Name = getEnv("DB_NAME", "order_service")
-	cfg.Database.SSLMode = getEnv("DB_SSLMODE", "disable")
-	cfg.Kafka.Brokers = []string{getEnv("KAFKA_BROKER", "kafka:9092")}
-	cfg.Kafka.Topic = getEnv("KAFKA_TOPIC", "orders")
-	cfg.Kafka.GroupID = getEnv("KAFKA_GROUP_ID", "order-service-group")
+	cfg.Server.Host = "0.0.0.0"
+	cfg.Server.Port = "8080"
+	cfg.Server.ReadTimeout = 10 * time.Second
+	cfg.Server.WriteTimeout = 10 * time.Second
+	cfg.Server.IdleTimeout = 60 * time.Second
+	cfg.Server.ShutdownTimeout = 30 * time.Second
+
+	cfg.Database.Host = "postgres"
+	cfg.Database.Port = "5432"
+	cfg.Database.User = "postgres"
+	cfg.Database.Name = "order_service"
+	cfg.Database.SSLMode = "disable"
+	cfg.Database.ConnectTimeout = 5 * time.Second
+	cfg.Database.QueryTimeout = 5 * time.Second
+	cfg.Database.MaxOpenConns = 25
+	cfg.Database.MaxIdleConns = 25
+	cfg.Database.ConnMaxLifetime = 5 * time.Minute
+	cfg.Database.RetryMaxAttempts = 5
+	cfg.Database.RetryInitialBackoff = 100 * time.Millisecond
+	cfg.Database.RetryMaxBackoff = 5 * time.Second
+	cfg.Database.BreakerThreshold = 5
+	cfg.Database.BreakerOpenTimeout = 10 * time.Second
+
+	cfg.Kafka.Brokers = []string{"kafka:9092"}
+	cfg.Kafka.Topic = "orders"
+	cfg.Kafka.GroupID = "order-service-group"
+	cfg.Kafka.DLQTopic = "orders-dlq"
+	cfg.Kafka.MinBytes = 10e3
+	cfg.Kafka.MaxBytes = 10e6
+	cfg.Kafka.CommitInterval = time.Second
+	cfg.Kafka.CommitBatchSize = 100
+	cfg.Kafka.StuckTimeout = time.Minute
+
+	cfg.Producer.Balancer = "hash"
+	cfg.Producer.Compression = "none"
+	cfg.Producer.RequiredAcks = -1
+	cfg.Producer.BatchSize = 100
+	cfg.Producer.BatchBytes = 1 << 20
+	cfg.Producer.BatchTimeout = 10 * time.Millisecond
+	cfg.Producer.AllowAutoTopicCreation = true
+
+	cfg.Processing.Workers = 8
+	cfg.Processing.QueueSize = 16
+	cfg.Processing.BatchSize = 1
+	cfg.Processing.BatchWindow = 50 * time.Millisecond
+
+	cfg.Seed.Count = 2
+
+	cfg.Outbox.Enabled = true
+	cfg.Outbox.Topic = "order-events"
+	cfg.Outbox.PollInterval = time.Second
+	cfg.Outbox.BatchSize = 100
+	cfg.Outbox.Retention = 7 * 24 * time.Hour
+
+	cfg.Cache.Capacity = 1000
+	cfg.Cache.WarmUpBatchSize = 500
+	cfg.Cache.TTL = 10 * time.Minute
+	cfg.Cache.ExpiryInterval = time.Minute
+	cfg.Cache.Policy = "lru"
 
 	return &cfg
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// Load собирает конфигурацию слоями: значения по умолчанию, YAML-файл
+// (флаг -config или CONFIG_FILE), переменные окружения и флаги командной
+// строки — каждый следующий слой переопределяет предыдущий
+func Load(args []string) (*Config, error) {
+	cfg := defaults()
+	opts := options(cfg)
+
+	fs := flag.NewFlagSet("order-service", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	byKey := make(map[string]option, len(opts))
+	for _, opt := range opts {
+		fs.String(opt.key, "", fmt.Sprintf("overrides %s (env %s)", opt.key, opt.env))
+		byKey[opt.key] = opt
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
 	}
-	return value
+
+	var errs []error
+	for _, opt := range opts {
+		if value := os.Getenv(opt.env); value != "" {
+			if err := opt.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", opt.env, err))
+			}
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if opt, ok := byKey[f.Name]; ok {
+			if err := opt.set(f.Value.String()); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %w", f.Name, err))
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Port != "", "server.port is required")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	check(c.Database.Host != "", "database.host is required")
+	check(c.Database.Port != "", "database.port is required")
+	check(c.Database.User != "", "database.user is required")
+	check(c.Database.Name != "", "database.name is required")
+	check(c.Database.ConnectTimeout > 0, "database.connect_timeout must be positive")
+	check(c.Database.QueryTimeout >= 0, "database.query_timeout must not be negative")
+	check(c.Database.MaxOpenConns > 0, "database.max_open_conns must be positive")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns must be between 0 and max_open_conns (%d)", c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.RetryMaxAttempts > 0, "database.retry_max_attempts must be positive")
+	check(c.Database.RetryInitialBackoff > 0, "database.retry_initial_backoff must be positive")
+	check(c.Database.RetryMaxBackoff >= c.Database.RetryInitialBackoff,
+		"database.retry_max_backoff must not be less than retry_initial_backoff")
+	check(c.Database.BreakerThreshold > 0, "database.breaker_threshold must be positive")
+	check(c.Database.BreakerOpenTimeout > 0, "database.breaker_open_timeout must be positive")
+
+	check(len(c.Kafka.Brokers) > 0, "kafka.brokers must contain at least one broker")
+	for _, broker := range c.Kafka.Brokers {
+		check(strings.TrimSpace(broker) != "", "kafka.brokers must not contain empty entries")
+	}
+	check(c.Kafka.Topic != "", "kafka.topic is required")
+	check(c.Kafka.GroupID != "", "kafka.group_id is required")
+	check(c.Kafka.DLQTopic != c.Kafka.Topic, "kafka.dlq_topic must differ from kafka.topic")
+	check(c.Kafka.MinBytes > 0, "kafka.min_bytes must be positive")
+	check(c.Kafka.MaxBytes >= c.Kafka.MinBytes, "kafka.max_bytes must not be less than min_bytes")
+	check(c.Kafka.CommitInterval > 0, "kafka.commit_interval must be positive")
+	check(c.Kafka.CommitBatchSize > 0, "kafka.commit_batch_size must be positive")
+	check(c.Kafka.MaxLag >= 0, "kafka.max_lag must not be negative")
+	check(c.Kafka.StuckTimeout > 0, "kafka.stuck_timeout must be positive")
+	check((c.Kafka.TLS.CertFile == "") == (c.Kafka.TLS.KeyFile == ""),
+		"kafka.tls.cert_file and kafka.tls.key_file must be set together")
+	switch strings.ToUpper(c.Kafka.SASL.Mechanism) {
+	case "":
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		check(c.Kafka.SASL.Username != "", "kafka.sasl.username is required for %s", c.Kafka.SASL.Mechanism)
+	default:
+		check(false, "kafka.sasl.mechanism %q is not supported (use PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512)",
+			c.Kafka.SASL.Mechanism)
+	}
+
+	switch c.Producer.Balancer {
+	case "hash", "murmur2", "least_bytes", "round_robin":
+	default:
+		check(false, "producer.balancer %q is not supported", c.Producer.Balancer)
+	}
+	switch c.Producer.Compression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		check(false, "producer.compression %q is not supported", c.Producer.Compression)
+	}
+	check(c.Producer.RequiredAcks >= -1 && c.Producer.RequiredAcks <= 1, "producer.required_acks must be -1, 0 or 1")
+	check(c.Producer.BatchSize > 0, "producer.batch_size must be positive")
+	check(c.Producer.BatchBytes > 0, "producer.batch_bytes must be positive")
+	check(c.Producer.BatchTimeout > 0, "producer.batch_timeout must be positive")
+
+	check(c.Processing.Workers > 0, "processing.workers must be positive")
+	check(c.Processing.QueueSize >= 0, "processing.queue_size must not be negative")
+	check(c.Processing.BatchSize > 0, "processing.batch_size must be positive")
+	check(c.Processing.BatchWindow >= 0, "processing.batch_window must not be negative")
+
+	if c.Seed.Enabled {
+		check(c.Seed.FixturesDir != "" || c.Seed.Count > 0, "seed.count must be positive when seed.fixtures_dir is empty")
+		check(c.Seed.Delay >= 0, "seed.delay must not be negative")
+	}
+
+	if c.Outbox.Enabled {
+		check(c.Outbox.Topic != "", "outbox.topic is required")
+		check(c.Outbox.PollInterval > 0, "outbox.poll_interval must be positive")
+		check(c.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+		check(c.Outbox.Retention >= 0, "outbox.retention must not be negative")
+	}
+
+	check(c.Cache.Capacity > 0, "cache.capacity must be positive")
+	check(c.Cache.WarmUpBatchSize > 0, "cache.warmup_batch_size must be positive")
+	check(c.Cache.MaxBytes >= 0, "cache.max_bytes must not be negative")
+	check(c.Cache.TTL >= 0, "cache.ttl must not be negative")
+	check(c.Cache.TTL == 0 || c.Cache.ExpiryInterval > 0, "cache.expiry_interval must be positive when cache.ttl is set")
+	check(c.Cache.Policy == "lru" || c.Cache.Policy == "fifo", "cache.policy %q is not supported (use lru or fifo)", c.Cache.Policy)
+
+	check(c.Admin.Token == "" || len(c.Admin.Token) >= 16, "admin.token must be at least 16 characters")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// RestartRequired перечисляет изменённые параметры, которые нельзя применить
+// без перезапуска. Остальное (размеры пула, таймаут запросов, ёмкость кэша, ретраи, пороги
+// проверки готовности) применяется по SIGHUP
+func (c *Config) RestartRequired(next *Config) []string {
+	var changed []string
+	diff := func(name string, equal bool) {
+		if !equal {
+			changed = append(changed, name)
+		}
+	}
+
+	diff("server", c.Server == next.Server)
+	diff("database.host", c.Database.Host == next.Database.Host)
+	diff("database.port", c.Database.Port == next.Database.Port)
+	diff("database.user", c.Database.User == next.Database.User)
+	diff("database.password", c.Database.Password == next.Database.Password)
+	diff("database.name", c.Database.Name == next.Database.Name)
+	diff("database.sslmode", c.Database.SSLMode == next.Database.SSLMode)
+	diff("database.breaker", c.Database.BreakerThreshold == next.Database.BreakerThreshold &&
+		c.Database.BreakerOpenTimeout == next.Database.BreakerOpenTimeout)
+	diff("kafka.brokers", strings.Join(c.Kafka.Brokers, ",") == strings.Join(next.Kafka.Brokers, ","))
+	diff("kafka.tls", c.Kafka.TLS == next.Kafka.TLS)
+	diff("kafka.sasl", c.Kafka.SASL == next.Kafka.SASL)
+	diff("kafka.topic", c.Kafka.Topic == next.Kafka.Topic)
+	diff("kafka.group_id", c.Kafka.GroupID == next.Kafka.GroupID)
+	diff("kafka.dlq_topic", c.Kafka.DLQTopic == next.Kafka.DLQTopic)
+	diff("kafka.min_bytes", c.Kafka.MinBytes == next.Kafka.MinBytes)
+	diff("kafka.max_bytes", c.Kafka.MaxBytes == next.Kafka.MaxBytes)
+	diff("kafka.commit", c.Kafka.CommitInterval == next.Kafka.CommitInterval &&
+		c.Kafka.CommitBatchSize == next.Kafka.CommitBatchSize)
+	diff("producer", c.Producer == next.Producer)
+	diff("processing", c.Processing == next.Processing)
+	diff("outbox", c.Outbox == next.Outbox)
+	diff("cache.warmup_batch_size", c.Cache.WarmUpBatchSize == next.Cache.WarmUpBatchSize)
+	diff("cache.max_bytes", c.Cache.MaxBytes == next.Cache.MaxBytes)
+	diff("cache.ttl", c.Cache.TTL == next.Cache.TTL && c.Cache.ExpiryInterval == next.Cache.ExpiryInterval)
+	diff("cache.policy", c.Cache.Policy == next.Cache.Policy)
+	diff("admin", c.Admin == next.Admin)
+	return changed
 }
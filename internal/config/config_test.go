@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeConfigFile(t, "server:\n  port: \"8081\"\ncache:\n  capacity: 50\n")
+
+	tests := []struct {
+		name     string
+		file     string
+		env      string
+		args     []string
+		wantPort string
+	}{
+		{name: "defaults", wantPort: "8080"},
+		{name: "yaml overrides defaults", file: file, wantPort: "8081"},
+		{name: "env overrides yaml", file: file, env: "8082", wantPort: "8082"},
+		{name: "flag overrides env", file: file, env: "8082", args: []string{"-server.port", "8083"}, wantPort: "8083"},
+		{name: "flag without yaml and env", args: []string{"-server.port=8084"}, wantPort: "8084"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", tt.file)
+			t.Setenv("SERVER_PORT", tt.env)
+
+			cfg, err := Load(tt.args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPort, cfg.Server.Port)
+			if tt.file != "" {
+				assert.Equal(t, 50, cfg.Cache.Capacity, "untouched yaml values must survive later layers")
+			} else {
+				assert.Equal(t, 1000, cfg.Cache.Capacity)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFlagOverridesEnvFile(t *testing.T) {
+	fromEnv := writeConfigFile(t, "server:\n  port: \"8081\"\n")
+	fromFlag := writeConfigFile(t, "server:\n  port: \"8082\"\n")
+	t.Setenv("CONFIG_FILE", fromEnv)
+	t.Setenv("SERVER_PORT", "")
+
+	cfg, err := Load([]string{"-config", fromFlag})
+	require.NoError(t, err)
+	assert.Equal(t, "8082", cfg.Server.Port)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+	}{
+		{name: "unknown yaml field", file: "server:\n  prot: \"8081\"\n"},
+		{name: "malformed yaml", file: "server: [\n"},
+		{name: "bad env duration", env: map[string]string{"SERVER_READ_TIMEOUT": "soon"}},
+		{name: "bad flag number", args: []string{"-cache.capacity", "many"}},
+		{name: "unknown flag", args: []string{"-no.such.option", "1"}},
+		{name: "invalid result", args: []string{"-processing.workers", "0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			if tt.file != "" {
+				t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.file))
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := Load(tt.args)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"missing port", func(c *Config) { c.Server.Port = "" }, "server.port is required"},
+		{"idle conns above open conns", func(c *Config) { c.Database.MaxIdleConns = c.Database.MaxOpenConns + 1 },
+			"database.max_idle_conns must be between"},
+		{"dlq equals topic", func(c *Config) { c.Kafka.DLQTopic = c.Kafka.Topic }, "kafka.dlq_topic must differ"},
+		{"tls key without cert", func(c *Config) { c.Kafka.TLS.KeyFile = "client.key" }, "must be set together"},
+		{"sasl without user", func(c *Config) { c.Kafka.SASL.Mechanism = "PLAIN" }, "kafka.sasl.username is required"},
+		{"unknown sasl", func(c *Config) { c.Kafka.SASL.Mechanism = "GSSAPI" }, "is not supported"},
+		{"bad acks", func(c *Config) { c.Producer.RequiredAcks = 2 }, "producer.required_acks"},
+		{"ttl without expiry", func(c *Config) { c.Cache.ExpiryInterval = 0 }, "cache.expiry_interval"},
+		{"unknown policy", func(c *Config) { c.Cache.Policy = "lfu" }, "cache.policy"},
+		{"short admin token", func(c *Config) { c.Admin.Token = "secret" }, "admin.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults()
+			require.NoError(t, cfg.Validate())
+
+			tt.modify(cfg)
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := defaults()
+	cfg.Server.Port = ""
+	cfg.Processing.Workers = 0
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.port")
+	assert.Contains(t, err.Error(), "processing.workers")
+}
+
+func TestRestartRequired(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{"nothing changed", func(c *Config) {}, nil},
+		{"hot parameters", func(c *Config) {
+			c.Database.QueryTimeout = time.Second
+			c.Database.MaxOpenConns++
+			c.Database.RetryMaxAttempts++
+			c.Cache.Capacity = 10
+			c.Kafka.MaxLag = 100
+		}, nil},
+		{"server", func(c *Config) { c.Server.Port = "9090" }, []string{"server"}},
+		{"brokers", func(c *Config) { c.Kafka.Brokers = []string{"other:9092"} }, []string{"kafka.brokers"}},
+		{"several", func(c *Config) {
+			c.Database.Host = "db2"
+			c.Cache.Policy = "fifo"
+			c.Admin.Token = "0123456789abcdef"
+		}, []string{"database.host", "cache.policy", "admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := defaults()
+			next := defaults()
+			tt.modify(next)
+			assert.Equal(t, tt.want, current.RestartRequired(next))
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// option связывает параметр конфигурации с переменной окружения и флагом
+type option struct {
+	key string
+	env string
+	set func(string) error
+}
+
+func options(cfg *Config) []option {
+	return []option{
+		stringOpt("server.host", "SERVER_HOST", &cfg.Server.Host),
+		stringOpt("server.port", "SERVER_PORT", &cfg.Server.Port),
+		durationOpt("server.read_timeout", "SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout),
+		durationOpt("server.write_timeout", "SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout),
+		durationOpt("server.idle_timeout", "SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout),
+		durationOpt("server.shutdown_timeout", "SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout),
+
+		stringOpt("database.host", "DB_HOST", &cfg.Database.Host),
+		stringOpt("database.port", "DB_PORT", &cfg.Database.Port),
+		stringOpt("database.user", "DB_USER", &cfg.Database.User),
+		stringOpt("database.password", "DB_PASSWORD", &cfg.Database.Password),
+		stringOpt("database.name", "DB_NAME", &cfg.Database.Name),
+		stringOpt("database.sslmode", "DB_SSLMODE", &cfg.Database.SSLMode),
+		durationOpt("database.connect_timeout", "DB_CONNECT_TIMEOUT", &cfg.Database.ConnectTimeout),
+		durationOpt("database.query_timeout", "DB_QUERY_TIMEOUT", &cfg.Database.QueryTimeout),
+		boolOpt("database.auto_migrate", "DB_AUTO_MIGRATE", &cfg.Database.AutoMigrate),
+		intOpt("database.max_open_conns", "DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns),
+		intOpt("database.max_idle_conns", "DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns),
+		durationOpt("database.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime),
+		intOpt("database.retry_max_attempts", "DB_RETRY_MAX_ATTEMPTS", &cfg.Database.RetryMaxAttempts),
+		durationOpt("database.retry_initial_backoff", "DB_RETRY_INITIAL_BACKOFF", &cfg.Database.RetryInitialBackoff),
+		durationOpt("database.retry_max_backoff", "DB_RETRY_MAX_BACKOFF", &cfg.Database.RetryMaxBackoff),
+		intOpt("database.breaker_threshold", "DB_BREAKER_THRESHOLD", &cfg.Database.BreakerThreshold),
+		durationOpt("database.breaker_open_timeout", "DB_BREAKER_OPEN_TIMEOUT", &cfg.Database.BreakerOpenTimeout),
+
+		// KAFKA_BROKER оставлен для совместимости; KAFKA_BROKERS принимает список через запятую
+		stringsOpt("kafka.broker", "KAFKA_BROKER", &cfg.Kafka.Brokers),
+		stringsOpt("kafka.brokers", "KAFKA_BROKERS", &cfg.Kafka.Brokers),
+		stringOpt("kafka.topic", "KAFKA_TOPIC", &cfg.Kafka.Topic),
+		stringOpt("kafka.group_id", "KAFKA_GROUP_ID", &cfg.Kafka.GroupID),
+		stringOpt("kafka.dlq_topic", "KAFKA_DLQ_TOPIC", &cfg.Kafka.DLQTopic),
+		intOpt("kafka.min_bytes", "KAFKA_MIN_BYTES", &cfg.Kafka.MinBytes),
+		intOpt("kafka.max_bytes", "KAFKA_MAX_BYTES", &cfg.Kafka.MaxBytes),
+		durationOpt("kafka.commit_interval", "KAFKA_COMMIT_INTERVAL", &cfg.Kafka.CommitInterval),
+		intOpt("kafka.commit_batch_size", "KAFKA_COMMIT_BATCH_SIZE", &cfg.Kafka.CommitBatchSize),
+		int64Opt("kafka.max_lag", "KAFKA_MAX_LAG", &cfg.Kafka.MaxLag),
+		durationOpt("kafka.stuck_timeout", "KAFKA_STUCK_TIMEOUT", &cfg.Kafka.StuckTimeout),
+		boolOpt("kafka.tls.enabled", "KAFKA_TLS_ENABLED", &cfg.Kafka.TLS.Enabled),
+		stringOpt("kafka.tls.ca_file", "KAFKA_TLS_CA_FILE", &cfg.Kafka.TLS.CAFile),
+		stringOpt("kafka.tls.cert_file", "KAFKA_TLS_CERT_FILE", &cfg.Kafka.TLS.CertFile),
+		stringOpt("kafka.tls.key_file", "KAFKA_TLS_KEY_FILE", &cfg.Kafka.TLS.KeyFile),
+		stringOpt("kafka.tls.server_name", "KAFKA_TLS_SERVER_NAME", &cfg.Kafka.TLS.ServerName),
+		boolOpt("kafka.tls.insecure_skip_verify", "KAFKA_TLS_INSECURE_SKIP_VERIFY", &cfg.Kafka.TLS.InsecureSkipVerify),
+		stringOpt("kafka.sasl.mechanism", "KAFKA_SASL_MECHANISM", &cfg.Kafka.SASL.Mechanism),
+		stringOpt("kafka.sasl.username", "KAFKA_SASL_USERNAME", &cfg.Kafka.SASL.Username),
+		stringOpt("kafka.sasl.password", "KAFKA_SASL_PASSWORD", &cfg.Kafka.SASL.Password),
+
+		stringOpt("producer.balancer", "KAFKA_PRODUCER_BALANCER", &cfg.Producer.Balancer),
+		stringOpt("producer.compression", "KAFKA_PRODUCER_COMPRESSION", &cfg.Producer.Compression),
+		intOpt("producer.required_acks", "KAFKA_PRODUCER_REQUIRED_ACKS", &cfg.Producer.RequiredAcks),
+		intOpt("producer.batch_size", "KAFKA_PRODUCER_BATCH_SIZE", &cfg.Producer.BatchSize),
+		int64Opt("producer.batch_bytes", "KAFKA_PRODUCER_BATCH_BYTES", &cfg.Producer.BatchBytes),
+		durationOpt("producer.batch_timeout", "KAFKA_PRODUCER_BATCH_TIMEOUT", &cfg.Producer.BatchTimeout),
+		boolOpt("producer.allow_auto_topic_creation", "KAFKA_PRODUCER_AUTO_CREATE_TOPICS", &cfg.Producer.AllowAutoTopicCreation),
+
+		intOpt("processing.workers", "PROCESSING_WORKERS", &cfg.Processing.Workers),
+		intOpt("processing.queue_size", "PROCESSING_QUEUE_SIZE", &cfg.Processing.QueueSize),
+		intOpt("processing.batch_size", "PROCESSING_BATCH_SIZE", &cfg.Processing.BatchSize),
+		durationOpt("processing.batch_window", "PROCESSING_BATCH_WINDOW", &cfg.Processing.BatchWindow),
+		boolOpt("processing.require_version", "PROCESSING_REQUIRE_VERSION", &cfg.Processing.RequireVersion),
+
+		boolOpt("seed.enabled", "SEED_ENABLED", &cfg.Seed.Enabled),
+		stringOpt("seed.fixtures_dir", "SEED_FIXTURES_DIR", &cfg.Seed.FixturesDir),
+		intOpt("seed.count", "SEED_COUNT", &cfg.Seed.Count),
+		durationOpt("seed.delay", "SEED_DELAY", &cfg.Seed.Delay),
+
+		boolOpt("outbox.enabled", "OUTBOX_ENABLED", &cfg.Outbox.Enabled),
+		stringOpt("outbox.topic", "OUTBOX_TOPIC", &cfg.Outbox.Topic),
+		durationOpt("outbox.poll_interval", "OUTBOX_POLL_INTERVAL", &cfg.Outbox.PollInterval),
+		intOpt("outbox.batch_size", "OUTBOX_BATCH_SIZE", &cfg.Outbox.BatchSize),
+		durationOpt("outbox.retention", "OUTBOX_RETENTION", &cfg.Outbox.Retention),
+
+		intOpt("cache.capacity", "CACHE_CAPACITY", &cfg.Cache.Capacity),
+		intOpt("cache.warmup_batch_size", "CACHE_WARMUP_BATCH_SIZE", &cfg.Cache.WarmUpBatchSize),
+		int64Opt("cache.max_bytes", "CACHE_MAX_BYTES", &cfg.Cache.MaxBytes),
+		durationOpt("cache.ttl", "CACHE_TTL", &cfg.Cache.TTL),
+		durationOpt("cache.expiry_interval", "CACHE_EXPIRY_INTERVAL", &cfg.Cache.ExpiryInterval),
+		stringOpt("cache.policy", "CACHE_POLICY", &cfg.Cache.Policy),
+
+		stringOpt("admin.token", "ADMIN_TOKEN", &cfg.Admin.Token),
+	}
+}
+
+func stringOpt(key, env string, dst *string) option {
+	return option{key: key, env: env, set: func(value string) error {
+		*dst = value
+		return nil
+	}}
+}
+
+func stringsOpt(key, env string, dst *[]string) option {
+	return option{key: key, env: env, set: func(value string) error {
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		*dst = values
+		return nil
+	}}
+}
+
+func boolOpt(key, env string, dst *bool) option {
+	return option{key: key, env: env, set: func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*dst = b
+		return nil
+	}}
+}
+
+func intOpt(key, env string, dst *int) option {
+	return option{key: key, env: env, set: func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*dst = n
+		return nil
+	}}
+}
+
+func int64Opt(key, env string, dst *int64) option {
+	return option{key: key, env: env, set: func(value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*dst = n
+		return nil
+	}}
+}
+
+func durationOpt(key, env string, dst *time.Duration) option {
+	return option{key: key, env: env, set: func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*dst = d
+		return nil
+	}}
+}
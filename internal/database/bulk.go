@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/metrics"
+	"order-service/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// maxParams — ограничение PostgreSQL на число параметров в одном запросе
+const maxParams = 65535
+
+// SaveOrders записывает пачку заказов в одной транзакции многострочными
+// INSERT'ами. Семантика та же, что у SaveOrder: устаревшие версии дают
+// WriteNoop, из нескольких версий одного заказа в пачке пишется самая новая.
+// Результаты возвращаются в порядке orders; при ошибке не пишется ничего
+func (p *Postgres) SaveOrders(ctx context.Context, orders []model.Order) ([]WriteResult, error) {
+	start := time.Now()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	results, err := p.saveOrders(ctx, orders)
+	err = ctxErr(ctx, constraintErr(err))
+	metrics.ObserveDB("save_orders", start, err)
+	return results, err
+}
+
+func (p *Postgres) saveOrders(ctx context.Context, orders []model.Order) ([]WriteResult, error) {
+	results := make([]WriteResult, len(orders))
+	for i := range results {
+		results[i] = WriteNoop
+	}
+
+	// Один INSERT ... ON CONFLICT не может изменить строку дважды, поэтому
+	// дубликаты order_uid отсекаются заранее
+	latest := make(map[string]int, len(orders))
+	for i := range orders {
+		if j, ok := latest[orders[i].OrderUID]; !ok || orders[i].Version >= orders[j].Version {
+			latest[orders[i].OrderUID] = i
+		}
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows := make([][]interface{}, 0, len(latest))
+	for i := range orders {
+		if latest[orders[i].OrderUID] != i {
+			continue
+		}
+		o := &orders[i]
+		rows = append(rows, []interface{}{
+			o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+			o.DeliveryService, o.Shardkey, o.SmID, o.DateCreated, o.OofShard, model.StatusCreated, o.Version,
+		})
+	}
+
+	var written, updated []string
+	for _, chunk := range chunkRows(rows) {
+		values, args := valuesList(chunk)
+		res, err := tx.QueryContext(ctx, `INSERT INTO orders (
+			order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status, version
+		) VALUES `+values+`
+		ON CONFLICT (order_uid) DO UPDATE SET
+			track_number = EXCLUDED.track_number, entry = EXCLUDED.entry,
+			locale = EXCLUDED.locale, internal_signature = EXCLUDED.internal_signature,
+			customer_id = EXCLUDED.customer_id, delivery_service = EXCLUDED.delivery_service,
+			shardkey = EXCLUDED.shardkey, sm_id = EXCLUDED.sm_id,
+			date_created = EXCLUDED.date_created, oof_shard = EXCLUDED.oof_shard,
+			version = EXCLUDED.version
+		WHERE orders.version < EXCLUDED.version OR (orders.version = 0 AND EXCLUDED.version = 0)
+		RETURNING order_uid, status, status_version, (xmax = 0) AS inserted`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upsert orders: %w", err)
+		}
+
+		for res.Next() {
+			var (
+				uid           string
+				status        model.OrderStatus
+				statusVersion int64
+				inserted      bool
+			)
+			if err := res.Scan(&uid, &status, &statusVersion, &inserted); err != nil {
+				res.Close()
+				return nil, fmt.Errorf("failed to scan upserted order: %w", err)
+			}
+
+			i := latest[uid]
+			orders[i].Status = status
+			orders[i].StatusVersion = statusVersion
+			written = append(written, uid)
+			if inserted {
+				results[i] = WriteInserted
+			} else {
+				results[i] = WriteUpdated
+				updated = append(updated, uid)
+			}
+		}
+		res.Close()
+		if err := res.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating upserted orders: %w", err)
+		}
+	}
+
+	if len(written) == 0 {
+		return results, nil
+	}
+
+	// У обновлённых заказов вложенные строки пересоздаются целиком: для пачек
+	// это дешевле построчного сравнения, которое делает SaveOrder
+	if len(updated) > 0 {
+		for _, table := range []string{"deliveries", "payments", "items"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE order_uid = ANY($1)", pq.Array(updated)); err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %w", table, err)
+			}
+		}
+	}
+
+	var history, deliveries, payments, items, events [][]interface{}
+	for _, uid := range written {
+		i := latest[uid]
+		o := &orders[i]
+
+		if results[i] == WriteInserted {
+			history = append(history, []interface{}{o.OrderUID, nil, model.StatusCreated, StatusSourceKafka})
+		}
+
+		d := o.Delivery
+		deliveries = append(deliveries, []interface{}{
+			o.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+		})
+
+		pm := o.Payment
+		payments = append(payments, []interface{}{
+			o.OrderUID, pm.Transaction, pm.RequestID, pm.Currency, pm.Provider, pm.Amount,
+			pm.PaymentDt, pm.Bank, pm.DeliveryCost, pm.GoodsTotal, pm.CustomFee,
+		})
+
+		for _, item := range o.Items {
+			items = append(items, []interface{}{
+				o.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
+				item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+			})
+		}
+
+		eventType := model.EventOrderUpdated
+		if results[i] == WriteInserted {
+			eventType = model.EventOrderCreated
+		}
+		payload, err := marshalOrderEvent(eventType, o)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, []interface{}{o.OrderUID, eventType, payload})
+	}
+
+	inserts := []struct {
+		table   string
+		columns string
+		rows    [][]interface{}
+	}{
+		{"order_status_history", "order_uid, from_status, to_status, source", history},
+		{"deliveries", "order_uid, name, phone, zip, city, address, region, email", deliveries},
+		{"payments", `order_uid, transaction, request_id, currency, provider, amount,
+			payment_dt, bank, delivery_cost, goods_total, custom_fee`, payments},
+		{"items", `order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status`, items},
+		{"outbox", "aggregate_id, event_type, payload", events},
+	}
+	for _, ins := range inserts {
+		if err := bulkInsert(ctx, tx, ins.table, ins.columns, ins.rows); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return results, nil
+}
+
+func bulkInsert(ctx context.Context, tx *sql.Tx, table, columns string, rows [][]interface{}) error {
+	for _, chunk := range chunkRows(rows) {
+		values, args := valuesList(chunk)
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+table+" ("+columns+") VALUES "+values, args...); err != nil {
+			return fmt.Errorf("failed to insert %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// chunkRows делит строки на части, укладывающиеся в maxParams
+func chunkRows(rows [][]interface{}) [][][]interface{} {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	size := maxParams / len(rows[0])
+	var chunks [][][]interface{}
+	for len(rows) > size {
+		chunks = append(chunks, rows[:size])
+		rows = rows[size:]
+	}
+	return append(chunks, rows)
+}
+
+// valuesList строит "($1, $2), ($3, $4)" и плоский список аргументов
+func valuesList(rows [][]interface{}) (string, []interface{}) {
+	var (
+		sb   strings.Builder
+		args = make([]interface{}, 0, len(rows)*len(rows[0]))
+	)
+	for i, row := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for j, v := range row {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			args = append(args, v)
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(len(args)))
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String(), args
+}
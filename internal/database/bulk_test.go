@@ -0,0 +1,36 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValuesList(t *testing.T) {
+	values, args := valuesList([][]interface{}{{"a", 1}, {"b", 2}})
+
+	if want := "($1, $2), ($3, $4)"; values != want {
+		t.Fatalf("values = %q, want %q", values, want)
+	}
+	if want := []interface{}{"a", 1, "b", 2}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("args = %v, want %v", args, want)
+	}
+}
+
+func TestChunkRowsRespectsParamLimit(t *testing.T) {
+	rows := make([][]interface{}, 10000)
+	for i := range rows {
+		rows[i] = make([]interface{}, 12)
+	}
+
+	chunks := chunkRows(rows)
+	total := 0
+	for _, chunk := range chunks {
+		if params := len(chunk) * 12; params > maxParams {
+			t.Fatalf("chunk has %d params, limit is %d", params, maxParams)
+		}
+		total += len(chunk)
+	}
+	if total != len(rows) || len(chunks) != 2 {
+		t.Fatalf("got %d rows in %d chunks, want %d rows in 2 chunks", total, len(chunks), len(rows))
+	}
+}
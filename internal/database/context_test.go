@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/internal/model"
+)
+
+// blockingDriver имитирует зависший сервер: любой запрос ждёт отмены контекста
+type blockingDriver struct{}
+
+func (blockingDriver) Open(string) (driver.Conn, error) { return blockingConn{}, nil }
+
+type blockingConn struct{}
+
+func (blockingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (blockingConn) Close() error                        { return nil }
+func (blockingConn) Begin() (driver.Tx, error)           { return blockingTx{}, nil }
+
+func (blockingConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return blockingTx{}, nil
+}
+
+func (blockingConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingConn) ExecContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type blockingTx struct{}
+
+func (blockingTx) Commit() error   { return nil }
+func (blockingTx) Rollback() error { return nil }
+
+func init() {
+	sql.Register("blocking", blockingDriver{})
+}
+
+func newBlockingPostgres(t *testing.T, queryTimeout time.Duration) *Postgres {
+	t.Helper()
+	db, err := sql.Open("blocking", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	p := &Postgres{db: db}
+	p.queryTimeout.Store(int64(queryTimeout))
+	return p
+}
+
+func TestQueriesHonourCancellation(t *testing.T) {
+	p := newBlockingPostgres(t, 0)
+
+	calls := map[string]func(ctx context.Context) error{
+		"GetOrderByUID": func(ctx context.Context) error {
+			_, err := p.GetOrderByUID(ctx, "uid")
+			return err
+		},
+		"SaveOrder": func(ctx context.Context) error {
+			_, err := p.SaveOrder(ctx, &model.Order{OrderUID: "uid"})
+			return err
+		},
+		"ListOrders": func(ctx context.Context) error {
+			_, err := p.ListOrders(ctx, model.OrderFilter{})
+			return err
+		},
+		"StreamRecentOrders": func(ctx context.Context) error {
+			return p.StreamRecentOrders(ctx, 10, 5, func([]model.Order) error { return nil })
+		},
+	}
+
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+
+			done := make(chan error, 1)
+			go func() { done <- call(ctx) }()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("err = %v, want context.Canceled", err)
+				}
+				if IsRetryable(err) {
+					t.Fatal("cancelled query must not be retried")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("query was not interrupted by cancellation")
+			}
+		})
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	p := newBlockingPostgres(t, 30*time.Millisecond)
+
+	start := time.Now()
+	_, err := p.GetOrderByUID(context.Background(), "uid")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("query ran for %v despite 30ms timeout", elapsed)
+	}
+	if !IsRetryable(err) {
+		t.Fatal("timed out query should be retryable")
+	}
+	if IsConnectionError(err) {
+		t.Fatal("timed out query must not be treated as an unavailable database")
+	}
+}
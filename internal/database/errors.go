@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/lib/pq"
+)
+
+// Нарушения ограничений схемы: заказ с такими данными не запишется и при
+// повторе, поэтому эти ошибки не временные
+var (
+	ErrDuplicateKey   = errors.New("duplicate key")
+	ErrMissingValue   = errors.New("required value is missing")
+	ErrCheckViolation = errors.New("check constraint violated")
+)
+
+// ConstraintError описывает нарушенное ограничение. errors.Is сопоставляет
+// её с одной из ошибок выше, а Err хранит исходную ошибку с *pq.Error внутри
+type ConstraintError struct {
+	Kind       error
+	Table      string
+	Column     string
+	Constraint string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// constraintErr оборачивает в *ConstraintError ошибки PostgreSQL о нарушении
+// уникальности, NOT NULL и CHECK; остальные ошибки возвращает как есть
+func constraintErr(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	var kind error
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		kind = ErrDuplicateKey
+	case "23502": // not_null_violation
+		kind = ErrMissingValue
+	case "23514": // check_violation
+		kind = ErrCheckViolation
+	default:
+		return err
+	}
+
+	return &ConstraintError{
+		Kind:       kind,
+		Table:      pqErr.Table,
+		Column:     pqErr.Column,
+		Constraint: pqErr.Constraint,
+		Err:        err,
+	}
+}
+
+// IsRetryable сообщает, стоит ли повторить операцию: ошибка соединения (см.
+// IsConnectionError), таймаут запроса, конфликт сериализации или взаимоблокировка.
+// Таймаут может повторяться на каждой попытке, поэтому число повторов должно
+// быть ограничено
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if IsConnectionError(err) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57014": // query_canceled, таймаут на стороне сервера
+			return true
+		}
+	}
+	return false
+}
+
+// IsConnectionError сообщает, что БД недоступна: потеря соединения, отказ в
+// подключении, перезапуск или перегрузка сервера. Такие ошибки проходят сами,
+// когда БД возвращается, и их можно повторять без ограничения. Таймаут
+// запроса сюда не относится: сервер отвечает, но запрос слишком медленный
+func IsConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", // connection_exception
+			"53": // insufficient_resources
+			return true
+		}
+		// Из класса 57 только остановка сервера; 57014 query_canceled — это
+		// statement_timeout или pg_cancel_backend, то есть медленный запрос
+		switch pqErr.Code {
+		case "57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	// context.DeadlineExceeded тоже реализует net.Error
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
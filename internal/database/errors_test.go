@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraintErr(t *testing.T) {
+	tests := []struct {
+		code pq.ErrorCode
+		kind error
+	}{
+		{"23505", ErrDuplicateKey},
+		{"23502", ErrMissingValue},
+		{"23514", ErrCheckViolation},
+	}
+
+	for _, tt := range tests {
+		pqErr := &pq.Error{Code: tt.code, Table: "items", Constraint: "items_order_uid_chrt_id_key"}
+		err := constraintErr(fmt.Errorf("failed to insert item: %w", pqErr))
+
+		var cerr *ConstraintError
+		require.ErrorAs(t, err, &cerr, "code %s", tt.code)
+		assert.ErrorIs(t, err, tt.kind)
+		assert.Equal(t, "items", cerr.Table)
+		assert.Equal(t, "items_order_uid_chrt_id_key", cerr.Constraint)
+		assert.False(t, IsRetryable(err), "code %s", tt.code)
+
+		var unwrapped *pq.Error
+		assert.ErrorAs(t, err, &unwrapped, "original driver error must stay reachable")
+	}
+
+	other := fmt.Errorf("failed: %w", &pq.Error{Code: "40001"})
+	assert.Same(t, other, constraintErr(other))
+
+	plain := errors.New("boom")
+	assert.Same(t, plain, constraintErr(plain))
+}
+
+func TestIsRetryable(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("failed to save order: %w", err) }
+	tests := []struct {
+		name       string
+		err        error
+		retryable  bool
+		connection bool
+	}{
+		{"nil", nil, false, false},
+		{"cancelled", wrap(context.Canceled), false, false},
+		{"query timeout", wrap(context.DeadlineExceeded), true, false},
+		{"connection failure", &pq.Error{Code: "08006"}, true, true},
+		{"too many connections", &pq.Error{Code: "53300"}, true, true},
+		{"admin shutdown", wrap(&pq.Error{Code: "57P01"}), true, true},
+		{"cannot connect now", &pq.Error{Code: "57P03"}, true, true},
+		{"statement timeout", wrap(&pq.Error{Code: "57014"}), true, false},
+		{"serialization failure", &pq.Error{Code: "40001"}, true, false},
+		{"deadlock", wrap(&pq.Error{Code: "40P01"}), true, false},
+		{"syntax error", &pq.Error{Code: "42601"}, false, false},
+		{"unique violation", constraintErr(&pq.Error{Code: "23505"}), false, false},
+		{"bad connection", wrap(driver.ErrBadConn), true, true},
+		{"eof", io.EOF, true, true},
+		{"unexpected eof", wrap(io.ErrUnexpectedEOF), true, true},
+		{"connection refused", wrap(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), true, true},
+		{"connection reset", wrap(syscall.ECONNRESET), true, true},
+		{"dns failure", &net.DNSError{Err: "no such host", Name: "postgres"}, true, true},
+		{"no rows", sql.ErrNoRows, false, false},
+		{"not found", ErrOrderNotFound, false, false},
+		{"plain error", errors.New("boom"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.retryable, IsRetryable(tt.err), "IsRetryable")
+			assert.Equal(t, tt.connection, IsConnectionError(tt.err), "IsConnectionError")
+		})
+	}
+}
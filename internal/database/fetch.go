@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"order-service/internal/metrics"
+	"order-service/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// orderJSONSelect собирает заказ o целиком в JSON с ключами из тегов model.Order;
+// FROM с таблицей orders o дописывает вызывающий, затем идут orderJSONJoins.
+// Это один оператор, поэтому заказ, доставка, оплата и товары читаются из
+// одного снимка и недописанный заказ увидеть нельзя
+const orderJSONSelect = `SELECT json_build_object(
+		'order_uid', o.order_uid, 'track_number', o.track_number, 'entry', o.entry,
+		'delivery', CASE WHEN d.order_uid IS NOT NULL THEN json_build_object(
+			'name', d.name, 'phone', d.phone, 'zip', d.zip, 'city', d.city,
+			'address', d.address, 'region', d.region, 'email', d.email) END,
+		'payment', CASE WHEN pm.order_uid IS NOT NULL THEN json_build_object(
+			'transaction', pm.transaction, 'request_id', pm.request_id, 'currency', pm.currency,
+			'provider', pm.provider, 'amount', pm.amount, 'payment_dt', pm.payment_dt, 'bank', pm.bank,
+			'delivery_cost', pm.delivery_cost, 'goods_total', pm.goods_total, 'custom_fee', pm.custom_fee) END,
+		'items', i.items,
+		'locale', o.locale, 'internal_signature', o.internal_signature, 'customer_id', o.customer_id,
+		'delivery_service', o.delivery_service, 'shardkey', o.shardkey, 'sm_id', o.sm_id,
+		'date_created', o.date_created, 'oof_shard', o.oof_shard, 'status', o.status, 'version', o.version,
+		'status_version', o.status_version)`
+
+const orderJSONJoins = `
+	LEFT JOIN deliveries d ON d.order_uid = o.order_uid
+	LEFT JOIN payments pm ON pm.order_uid = o.order_uid
+	LEFT JOIN LATERAL (
+		SELECT json_agg(json_build_object(
+			'chrt_id', it.chrt_id, 'track_number', it.track_number, 'price', it.price, 'rid', it.rid,
+			'name', it.name, 'sale', it.sale, 'size', it.size, 'total_price', it.total_price,
+			'nm_id', it.nm_id, 'brand', it.brand, 'status', it.status) ORDER BY it.id) AS items
+		FROM items it WHERE it.order_uid = o.order_uid
+	) i ON true`
+
+// orderJSONQuery читает заказы по списку order_uid. Порядок строк совпадает
+// с порядком order_uid в $1, отсутствующие заказы пропускаются
+const orderJSONQuery = orderJSONSelect + `
+	FROM unnest($1::varchar[]) WITH ORDINALITY AS u(order_uid, n)
+	JOIN orders o ON o.order_uid = u.order_uid` + orderJSONJoins + `
+	ORDER BY u.n`
+
+// GetOrderByUID возвращает заказ или nil, если его нет
+func (p *Postgres) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+	start := time.Now()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	orders, err := p.fetchOrders(ctx, []string{orderUID})
+	err = ctxErr(ctx, err)
+	metrics.ObserveDB("get_order_by_uid", start, err)
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// GetOrdersByUIDs читает заказы одним запросом в порядке uids; ненайденные
+// пропускаются, повторы в uids читаются один раз
+func (p *Postgres) GetOrdersByUIDs(ctx context.Context, uids []string) ([]model.Order, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	start := time.Now()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	orders, err := p.fetchOrders(ctx, uniqueStrings(uids))
+	err = ctxErr(ctx, err)
+	metrics.ObserveDB("get_orders_by_uids", start, err)
+	return orders, err
+}
+
+func (p *Postgres) fetchOrders(ctx context.Context, uids []string) ([]model.Order, error) {
+	return p.queryOrders(ctx, len(uids), orderJSONQuery, pq.Array(uids))
+}
+
+// queryOrders выполняет запрос, построенный на orderJSONSelect, и декодирует
+// заказы; size — ожидаемое число строк
+func (p *Postgres) queryOrders(ctx context.Context, size int, query string, args ...interface{}) ([]model.Order, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]model.Order, 0, size)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+
+		var order model.Order
+		if err := json.Unmarshal(data, &order); err != nil {
+			return nil, fmt.Errorf("failed to decode order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+	return orders, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
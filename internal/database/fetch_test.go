@@ -0,0 +1,379 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+
+	"order-service/internal/model"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// perTableGetOrderByUID — прежняя реализация GetOrderByUID (четыре запроса
+// вне транзакции), оставлена для сравнения в тестах и бенчмарках
+func (p *Postgres) perTableGetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+	var order model.Order
+
+	orderQuery := `SELECT order_uid, track_number, entry, locale, internal_signature, 
+		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status, version
+		FROM orders WHERE order_uid = $1`
+
+	err := p.db.QueryRowContext(ctx, orderQuery, orderUID).Scan(
+		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
+		&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
+		&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard, &order.Status, &order.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	var delivery model.Delivery
+	deliveryQuery := `SELECT name, phone, zip, city, address, region, email 
+		FROM deliveries WHERE order_uid = $1`
+
+	err = p.db.QueryRowContext(ctx, deliveryQuery, orderUID).Scan(
+		&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City,
+		&delivery.Address, &delivery.Region, &delivery.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+	order.Delivery = delivery
+
+	var payment model.Payment
+	paymentQuery := `SELECT transaction, request_id, currency, provider, amount,
+		payment_dt, bank, delivery_cost, goods_total, custom_fee 
+		FROM payments WHERE order_uid = $1`
+
+	err = p.db.QueryRowContext(ctx, paymentQuery, orderUID).Scan(
+		&payment.Transaction, &payment.RequestID, &payment.Currency,
+		&payment.Provider, &payment.Amount, &payment.PaymentDt, &payment.Bank,
+		&payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	order.Payment = payment
+
+	itemsQuery := `SELECT chrt_id, track_number, price, rid, name, sale, size,
+		total_price, nm_id, brand, status FROM items WHERE order_uid = $1 ORDER BY id`
+
+	rows, err := p.db.QueryContext(ctx, itemsQuery, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []model.Item
+	for rows.Next() {
+		var item model.Item
+		err := rows.Scan(
+			&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid,
+			&item.Name, &item.Sale, &item.Size, &item.TotalPrice,
+			&item.NmID, &item.Brand, &item.Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating items: %w", err)
+	}
+
+	order.Items = items
+
+	return &order, nil
+}
+
+// perTableGetOrdersByUIDs читает пачку заказов прежним способом: заказы,
+// затем доставки, оплаты и товары отдельными запросами
+func (p *Postgres) perTableGetOrdersByUIDs(ctx context.Context, uids []string) ([]model.Order, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT order_uid, track_number, entry, locale, internal_signature,
+		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status, version
+		FROM orders WHERE order_uid = ANY($1)`, pq.Array(uids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []model.Order
+	for rows.Next() {
+		var order model.Order
+		if err := rows.Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
+			&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
+			&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard, &order.Status, &order.Version); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return orders, p.loadOrderDetails(ctx, orders)
+}
+
+// normalizeTime приводит date_created к UTC: из JSON и из lib/pq время
+// приходит с разными *time.Location
+func normalizeTime(orders ...model.Order) []model.Order {
+	for i := range orders {
+		orders[i].DateCreated = orders[i].DateCreated.UTC()
+	}
+	return orders
+}
+
+// loadOrderDetails — прежняя дозагрузка доставки, оплаты и товаров для набора
+// заказов тремя запросами, оставлена для сравнения в бенчмарке
+func (p *Postgres) loadOrderDetails(ctx context.Context, orders []model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	uids := make([]string, len(orders))
+	index := make(map[string]*model.Order, len(orders))
+	for i := range orders {
+		uids[i] = orders[i].OrderUID
+		index[orders[i].OrderUID] = &orders[i]
+	}
+
+	deliveryRows, err := p.db.QueryContext(ctx, `SELECT order_uid, name, phone, zip, city, address, region, email
+		FROM deliveries WHERE order_uid = ANY($1)`, pq.Array(uids))
+	if err != nil {
+		return fmt.Errorf("failed to get deliveries: %w", err)
+	}
+	defer deliveryRows.Close()
+
+	for deliveryRows.Next() {
+		var uid string
+		var d model.Delivery
+		if err := deliveryRows.Scan(&uid, &d.Name, &d.Phone, &d.Zip, &d.City,
+			&d.Address, &d.Region, &d.Email); err != nil {
+			return fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		if order, ok := index[uid]; ok {
+			order.Delivery = d
+		}
+	}
+	if err := deliveryRows.Err(); err != nil {
+		return fmt.Errorf("error iterating deliveries: %w", err)
+	}
+
+	paymentRows, err := p.db.QueryContext(ctx, `SELECT order_uid, transaction, request_id, currency, provider, amount,
+		payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM payments WHERE order_uid = ANY($1)`, pq.Array(uids))
+	if err != nil {
+		return fmt.Errorf("failed to get payments: %w", err)
+	}
+	defer paymentRows.Close()
+
+	for paymentRows.Next() {
+		var uid string
+		var pm model.Payment
+		if err := paymentRows.Scan(&uid, &pm.Transaction, &pm.RequestID, &pm.Currency,
+			&pm.Provider, &pm.Amount, &pm.PaymentDt, &pm.Bank, &pm.DeliveryCost,
+			&pm.GoodsTotal, &pm.CustomFee); err != nil {
+			return fmt.Errorf("failed to scan payment: %w", err)
+		}
+		if order, ok := index[uid]; ok {
+			order.Payment = pm
+		}
+	}
+	if err := paymentRows.Err(); err != nil {
+		return fmt.Errorf("error iterating payments: %w", err)
+	}
+
+	itemRows, err := p.db.QueryContext(ctx, `SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size,
+		total_price, nm_id, brand, status FROM items WHERE order_uid = ANY($1) ORDER BY id`, pq.Array(uids))
+	if err != nil {
+		return fmt.Errorf("failed to get items: %w", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var uid string
+		var item model.Item
+		if err := itemRows.Scan(&uid, &item.ChrtID, &item.TrackNumber, &item.Price,
+			&item.Rid, &item.Name, &item.Sale, &item.Size, &item.TotalPrice,
+			&item.NmID, &item.Brand, &item.Status); err != nil {
+			return fmt.Errorf("failed to scan item: %w", err)
+		}
+		if order, ok := index[uid]; ok {
+			order.Items = append(order.Items, item)
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return fmt.Errorf("error iterating items: %w", err)
+	}
+
+	return nil
+}
+
+func TestIntegrationGetOrdersByUIDs(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	for _, uid := range []string{"a", "b", "c"} {
+		order := integrationOrder(uid, 1)
+		order.Items = append(order.Items, model.Item{ChrtID: 1, TrackNumber: order.TrackNumber, Name: "Brush", Price: 100, TotalPrice: 100})
+		_, err := pg.SaveOrder(ctx, &order)
+		require.NoError(t, err)
+	}
+	empty := integrationOrder("empty", 1)
+	empty.Items = nil
+	_, err := pg.SaveOrder(ctx, &empty)
+	require.NoError(t, err)
+
+	got, err := pg.GetOrdersByUIDs(ctx, []string{"c", "missing", "a", "empty", "c"})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, "c", got[0].OrderUID)
+	assert.Equal(t, "a", got[1].OrderUID)
+	assert.Equal(t, "empty", got[2].OrderUID)
+	assert.Nil(t, got[2].Items)
+
+	for _, order := range got {
+		legacy, err := pg.perTableGetOrderByUID(ctx, order.OrderUID)
+		require.NoError(t, err)
+		assert.Equal(t, normalizeTime(*legacy), normalizeTime(order), "order %s", order.OrderUID)
+
+		single, err := pg.GetOrderByUID(ctx, order.OrderUID)
+		require.NoError(t, err)
+		assert.Equal(t, normalizeTime(order), normalizeTime(*single))
+	}
+
+	none, err := pg.GetOrdersByUIDs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestMemoryGetOrdersByUIDs(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	for _, uid := range []string{"a", "b", "c"} {
+		order := integrationOrder(uid, 1)
+		_, err := m.SaveOrder(ctx, &order)
+		require.NoError(t, err)
+	}
+
+	got, err := m.GetOrdersByUIDs(ctx, []string{"c", "missing", "a", "c"})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "c", got[0].OrderUID)
+	assert.Equal(t, "a", got[1].OrderUID)
+}
+
+// Заказ версии v пишется с amount = v и ценой товара v; читатель не должен
+// увидеть их из разных версий
+func TestIntegrationGetOrderByUIDConsistentSnapshot(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	version := func(v int64) model.Order {
+		order := integrationOrder("hot", v)
+		order.Payment.Amount = v
+		order.Items[0].Price = v
+		return order
+	}
+	first := version(1)
+	_, err := pg.SaveOrder(ctx, &first)
+	require.NoError(t, err)
+
+	const versions = 200
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := int64(2); v <= versions; v++ {
+			order := version(v)
+			if _, err := pg.SaveOrder(ctx, &order); err != nil {
+				t.Errorf("save version %d: %v", v, err)
+				return
+			}
+		}
+	}()
+
+	for !t.Failed() {
+		got, err := pg.GetOrderByUID(ctx, "hot")
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		require.Len(t, got.Items, 1)
+		require.Equal(t, got.Version, got.Payment.Amount, "payment from another version")
+		require.Equal(t, got.Version, got.Items[0].Price, "items from another version")
+		if got.Version == versions {
+			break
+		}
+	}
+	wg.Wait()
+}
+
+func benchmarkOrders(b *testing.B, n int) (*Postgres, []string) {
+	pg := integrationDB(b)
+
+	orders := make([]model.Order, n)
+	uids := make([]string, n)
+	for i := range orders {
+		uids[i] = fmt.Sprintf("bench-%d", i)
+		orders[i] = integrationOrder(uids[i], 1)
+		for j := 1; j < 5; j++ {
+			item := orders[i].Items[0]
+			item.ChrtID += j
+			orders[i].Items = append(orders[i].Items, item)
+		}
+	}
+	_, err := pg.SaveOrders(context.Background(), orders)
+	require.NoError(b, err)
+	return pg, uids
+}
+
+// go test -run=^$ -bench=GetOrder ./internal/database (нужен PostgreSQL, см. integrationDB)
+func BenchmarkGetOrderByUID(b *testing.B) {
+	pg, uids := benchmarkOrders(b, 100)
+	ctx := context.Background()
+
+	for _, bm := range []struct {
+		name string
+		get  func(context.Context, string) (*model.Order, error)
+	}{
+		{"json", pg.GetOrderByUID},
+		{"per_table", pg.perTableGetOrderByUID},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := bm.get(ctx, uids[i%len(uids)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetOrdersByUIDs(b *testing.B) {
+	pg, uids := benchmarkOrders(b, 100)
+	ctx := context.Background()
+
+	for _, bm := range []struct {
+		name string
+		get  func(context.Context, []string) ([]model.Order, error)
+	}{
+		{"json", pg.GetOrdersByUIDs},
+		{"per_table", pg.perTableGetOrdersByUIDs},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				orders, err := bm.get(ctx, uids)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(orders) != len(uids) {
+					b.Fatalf("got %d orders, want %d", len(orders), len(uids))
+				}
+			}
+		})
+	}
+}
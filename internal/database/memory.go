@@ -0,0 +1,343 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"order-service/internal/model"
+)
+
+// Memory хранит заказы в памяти с той же семантикой, что и Postgres:
+// запись по версиям, статусы с историей, keyset-пагинация. Подходит для
+// тестов и запуска без БД; данные теряются при остановке, событий outbox нет
+type Memory struct {
+	mu      sync.RWMutex
+	orders  map[string]model.Order
+	history map[string][]model.StatusChange
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		orders:  make(map[string]model.Order),
+		history: make(map[string][]model.StatusChange),
+	}
+}
+
+func (m *Memory) SaveOrder(ctx context.Context, order *model.Order) (WriteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err := checkItemsUnique(order); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.save(order), nil
+}
+
+func (m *Memory) SaveOrders(ctx context.Context, orders []model.Order) ([]WriteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Как и в Postgres, из нескольких версий одного заказа пишется самая новая
+	latest := make(map[string]int, len(orders))
+	for i := range orders {
+		if err := checkItemsUnique(&orders[i]); err != nil {
+			return nil, err
+		}
+		if j, ok := latest[orders[i].OrderUID]; !ok || orders[i].Version >= orders[j].Version {
+			latest[orders[i].OrderUID] = i
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]WriteResult, len(orders))
+	for i := range orders {
+		results[i] = WriteNoop
+		if latest[orders[i].OrderUID] == i {
+			results[i] = m.save(&orders[i])
+		}
+	}
+	return results, nil
+}
+
+// newerVersion повторяет условие upsert в Postgres: версия должна быть больше
+// сохранённой, а заказ без версии заменяет только заказ без версии
+func newerVersion(version, stored int64) bool {
+	return version > stored || (version == 0 && stored == 0)
+}
+
+func (m *Memory) save(order *model.Order) WriteResult {
+	stored, exists := m.orders[order.OrderUID]
+	if exists && !newerVersion(order.Version, stored.Version) {
+		return WriteNoop
+	}
+
+	result := WriteUpdated
+	if exists {
+		order.Status = stored.Status
+		order.StatusVersion = stored.StatusVersion
+	} else {
+		result = WriteInserted
+		order.Status = model.StatusCreated
+		m.history[order.OrderUID] = append(m.history[order.OrderUID], model.StatusChange{
+			OrderUID:  order.OrderUID,
+			ToStatus:  model.StatusCreated,
+			Source:    StatusSourceKafka,
+			ChangedAt: time.Now(),
+		})
+	}
+
+	m.orders[order.OrderUID] = cloneOrder(*order)
+	return result
+}
+
+func (m *Memory) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, ok := m.orders[orderUID]
+	if !ok {
+		return nil, nil
+	}
+	order = cloneOrder(order)
+	return &order, nil
+}
+
+func (m *Memory) GetOrdersByUIDs(ctx context.Context, uids []string) ([]model.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var orders []model.Order
+	for _, uid := range uniqueStrings(uids) {
+		if order, ok := m.orders[uid]; ok {
+			orders = append(orders, cloneOrder(order))
+		}
+	}
+	return orders, nil
+}
+
+func (m *Memory) StreamRecentOrders(ctx context.Context, limit, batchSize int, fn func([]model.Order) error) error {
+	if limit <= 0 {
+		return nil
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	orders := m.sorted(model.SortDateCreatedDesc)
+	if len(orders) > limit {
+		orders = orders[:limit]
+	}
+
+	for len(orders) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := min(batchSize, len(orders))
+		if err := fn(orders[:n]); err != nil {
+			return err
+		}
+		orders = orders[n:]
+	}
+	return nil
+}
+
+func (m *Memory) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filter, err := normalizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *cursor
+	if filter.Cursor != "" {
+		if after, err = decodeCursor(filter.Cursor, filter.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	less := orderLess(filter.Sort)
+	orders := make([]model.Order, 0, filter.Limit+1)
+	for _, order := range m.sorted(filter.Sort) {
+		if !matchesFilter(&order, &filter) {
+			continue
+		}
+		if after != nil && !less(model.Order{OrderUID: after.OrderUID, DateCreated: after.DateCreated}, order) {
+			continue
+		}
+		orders = append(orders, order)
+		if len(orders) > filter.Limit {
+			break
+		}
+	}
+
+	page := &model.OrderPage{Orders: orders}
+	if len(orders) > filter.Limit {
+		page.Orders = orders[:filter.Limit]
+		last := page.Orders[len(page.Orders)-1]
+		page.NextCursor = encodeCursor(cursor{
+			Sort:        filter.Sort,
+			OrderUID:    last.OrderUID,
+			DateCreated: last.DateCreated,
+		})
+	}
+	return page, nil
+}
+
+func (m *Memory) GetOrderStatus(ctx context.Context, orderUID string) (model.OrderStatus, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, ok := m.orders[orderUID]
+	if !ok {
+		return "", ErrOrderNotFound
+	}
+	return order.Status, nil
+}
+
+func (m *Memory) ChangeOrderStatus(ctx context.Context, orderUID string, to model.OrderStatus, source string) (*model.StatusChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.orders[orderUID]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	if !order.Status.CanTransitionTo(to) {
+		return nil, &model.TransitionError{From: order.Status, To: to}
+	}
+
+	change := model.StatusChange{
+		OrderUID:      orderUID,
+		FromStatus:    order.Status,
+		ToStatus:      to,
+		Source:        source,
+		ChangedAt:     time.Now(),
+		StatusVersion: order.StatusVersion + 1,
+	}
+	order.Status = to
+	order.StatusVersion = change.StatusVersion
+	m.orders[orderUID] = order
+	m.history[orderUID] = append(m.history[orderUID], change)
+	return &change, nil
+}
+
+func (m *Memory) GetOrderStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.orders[orderUID]; !ok {
+		return nil, ErrOrderNotFound
+	}
+	return append([]model.StatusChange{}, m.history[orderUID]...), nil
+}
+
+// sorted возвращает копии всех заказов в порядке sort
+func (m *Memory) sorted(sortBy string) []model.Order {
+	m.mu.RLock()
+	orders := make([]model.Order, 0, len(m.orders))
+	for _, order := range m.orders {
+		orders = append(orders, cloneOrder(order))
+	}
+	m.mu.RUnlock()
+
+	less := orderLess(sortBy)
+	sort.Slice(orders, func(i, j int) bool { return less(orders[i], orders[j]) })
+	return orders
+}
+
+// orderLess повторяет ORDER BY из ListOrders: по дате с order_uid для
+// однозначности или только по order_uid
+func orderLess(sortBy string) func(a, b model.Order) bool {
+	desc := strings.HasPrefix(sortBy, "-")
+	byDate := strings.TrimPrefix(sortBy, "-") == model.SortDateCreatedAsc
+
+	return func(a, b model.Order) bool {
+		if desc {
+			a, b = b, a
+		}
+		if byDate && !a.DateCreated.Equal(b.DateCreated) {
+			return a.DateCreated.Before(b.DateCreated)
+		}
+		return a.OrderUID < b.OrderUID
+	}
+}
+
+func matchesFilter(order *model.Order, filter *model.OrderFilter) bool {
+	switch {
+	case filter.CustomerID != "" && order.CustomerID != filter.CustomerID,
+		filter.TrackNumber != "" && order.TrackNumber != filter.TrackNumber,
+		filter.DateFrom != nil && order.DateCreated.Before(*filter.DateFrom),
+		filter.DateTo != nil && !order.DateCreated.Before(*filter.DateTo),
+		filter.DeliveryService != "" && order.DeliveryService != filter.DeliveryService,
+		filter.Locale != "" && order.Locale != filter.Locale:
+		return false
+	}
+
+	return (filter.Brand == "" || hasItem(order, func(item model.Item) bool { return item.Brand == filter.Brand })) &&
+		(filter.NmID == nil || hasItem(order, func(item model.Item) bool { return item.NmID == *filter.NmID }))
+}
+
+func hasItem(order *model.Order, match func(model.Item) bool) bool {
+	for _, item := range order.Items {
+		if match(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkItemsUnique повторяет ограничение UNIQUE (order_uid, chrt_id) таблицы items
+func checkItemsUnique(order *model.Order) error {
+	seen := make(map[int]bool, len(order.Items))
+	for _, item := range order.Items {
+		if seen[item.ChrtID] {
+			return &ConstraintError{
+				Kind:       ErrDuplicateKey,
+				Table:      "items",
+				Constraint: "items_order_uid_chrt_id_key",
+				Err:        fmt.Errorf("order %s has several items with chrt_id %d", order.OrderUID, item.ChrtID),
+			}
+		}
+		seen[item.ChrtID] = true
+	}
+	return nil
+}
+
+func cloneOrder(order model.Order) model.Order {
+	order.Items = append([]model.Item(nil), order.Items...)
+	return order
+}
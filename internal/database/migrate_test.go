@@ -0,0 +1,48 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"order-service/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_add_index.up.sql":   {Data: []byte("CREATE INDEX i ON t(c);")},
+		"001_init.up.sql":        {Data: []byte("CREATE TABLE t (c INT);")},
+		"001_init.down.sql":      {Data: []byte("DROP TABLE t;")},
+		"embed.go":               {Data: []byte("package migrations")},
+		"003_bad_name.sideways":  {Data: []byte("ignored")},
+		"002_add_index.down.sql": {Data: []byte("DROP INDEX i;")},
+	}
+
+	list, err := LoadMigrations(fsys)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, Migration{Version: 1, Name: "init", Up: "CREATE TABLE t (c INT);", Down: "DROP TABLE t;"}, list[0])
+	assert.Equal(t, int64(2), list[1].Version)
+
+	_, err = LoadMigrations(fstest.MapFS{"001_init.down.sql": {Data: []byte("DROP TABLE t;")}})
+	assert.Error(t, err, "down script without up script")
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"001_init.up.sql":  {Data: []byte("SELECT 1;")},
+		"001_other.up.sql": {Data: []byte("SELECT 1;")},
+	})
+	assert.Error(t, err, "two migrations with the same version")
+}
+
+func TestEmbeddedMigrationsAreReversible(t *testing.T) {
+	list, err := LoadMigrations(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	for i, m := range list {
+		assert.Equal(t, int64(i+1), m.Version, "versions must be contiguous")
+		assert.NotEmpty(t, m.Down, "migration %03d_%s has no down script", m.Version, m.Name)
+	}
+}
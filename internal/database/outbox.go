@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"order-service/internal/model"
+
+	"github.com/lib/pq"
+)
+
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, eventType string, order *model.Order) error {
+	payload, err := marshalOrderEvent(eventType, order)
+	if err != nil {
+		return err
+	}
+	return insertOutboxRow(ctx, tx, order.OrderUID, eventType, payload)
+}
+
+// insertStatusEvent пишет в outbox событие OrderStatusChanged; version — текущая
+// версия заказа, статус её не меняет
+func insertStatusEvent(ctx context.Context, tx *sql.Tx, version int64, change *model.StatusChange) error {
+	payload, err := json.Marshal(model.OrderEvent{
+		Type:         model.EventOrderStatusChanged,
+		OrderUID:     change.OrderUID,
+		Version:      version,
+		OccurredAt:   change.ChangedAt.UTC(),
+		StatusChange: change,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox event: %w", err)
+	}
+	return insertOutboxRow(ctx, tx, change.OrderUID, model.EventOrderStatusChanged, payload)
+}
+
+func insertOutboxRow(ctx context.Context, tx *sql.Tx, aggregateID, eventType string, payload []byte) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)`,
+		aggregateID, eventType, payload)
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox event: %w", err)
+	}
+	return nil
+}
+
+func marshalOrderEvent(eventType string, order *model.Order) ([]byte, error) {
+	payload, err := json.Marshal(model.OrderEvent{
+		Type:       eventType,
+		OrderUID:   order.OrderUID,
+		Version:    order.Version,
+		OccurredAt: time.Now().UTC(),
+		Order:      order,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox event: %w", err)
+	}
+	return payload, nil
+}
+
+// RelayOutbox забирает до limit неопубликованных событий, передаёт их в publish
+// и помечает опубликованными в той же транзакции. FOR UPDATE SKIP LOCKED не
+// даёт нескольким репликам публиковать одни и те же события одновременно.
+// Если publish вернул ошибку, события остаются в outbox до следующей попытки
+func (p *Postgres) RelayOutbox(ctx context.Context, limit int, publish func([]model.OutboxEvent) error) (int, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	n, err := p.relayOutbox(ctx, limit, publish)
+	return n, ctxErr(ctx, err)
+}
+
+func (p *Postgres) relayOutbox(ctx context.Context, limit int, publish func([]model.OutboxEvent) error) (int, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, aggregate_id, event_type, payload, created_at
+		FROM outbox WHERE published_at IS NULL
+		ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get outbox events: %w", err)
+	}
+
+	var (
+		events []model.OutboxEvent
+		ids    []int64
+	)
+	for rows.Next() {
+		var e model.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.AggregateID, &e.EventType, &e.Payload, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, e)
+		ids = append(ids, e.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating outbox events: %w", err)
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(events); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE outbox SET published_at = NOW() WHERE id = ANY($1)", pq.Array(ids)); err != nil {
+		return 0, fmt.Errorf("failed to mark outbox events as published: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(events), nil
+}
+
+// PurgeOutbox удаляет события, опубликованные раньше olderThan
+func (p *Postgres) PurgeOutbox(ctx context.Context, olderThan time.Time) (int64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, "DELETE FROM outbox WHERE published_at < $1", olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -3,111 +3,241 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
+	"order-service/internal/config"
+	"order-service/internal/metrics"
 	"order-service/internal/model"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Postgres struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout atomic.Int64
 }
 
-func NewPostgres(host, port, user, password, dbname, sslmode string) (*Postgres, error) {
+func NewPostgres(cfg *config.Config) (*Postgres, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode)
+		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password,
+		cfg.Database.Name, cfg.Database.SSLMode)
 
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.ConnectTimeout)
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %v", err)
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	p := &Postgres{db: db}
+	p.Reconfigure(cfg)
 
 	log.Println("Successfully connected to PostgreSQL database")
-	return &Postgres{db: db}, nil
+	return p, nil
+}
+
+// Reconfigure применяет настройки пула соединений и таймаут запросов;
+// безопасно вызывать на ходу
+func (p *Postgres) Reconfigure(cfg *config.Config) {
+	p.db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	p.db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	p.db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	p.queryTimeout.Store(int64(cfg.Database.QueryTimeout))
+}
+
+// withTimeout ограничивает операцию временем database.query_timeout
+func (p *Postgres) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := time.Duration(p.queryTimeout.Load()); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// ctxErr подменяет ошибку прерванного запроса ошибкой контекста: при отмене
+// lib/pq возвращает "canceling statement due to user request", по которой не
+// отличить таймаут от отмены
+func ctxErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+		return fmt.Errorf("%v: %w", err, ctx.Err())
+	}
+	return err
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+func (p *Postgres) Stats() sql.DBStats {
+	return p.db.Stats()
 }
 
 func (p *Postgres) Close() error {
 	return p.db.Close()
 }
 
-func (p *Postgres) SaveOrder(order *model.Order) error {
-	tx, err := p.db.Begin()
+type WriteResult string
+
+const (
+	WriteInserted WriteResult = "insert"
+	WriteUpdated  WriteResult = "update"
+	WriteNoop     WriteResult = "noop"
+)
+
+// SaveOrder записывает заказ, если его версия новее сохранённой. Повторно
+// доставленные и устаревшие сообщения ничего не меняют и дают WriteNoop
+func (p *Postgres) SaveOrder(ctx context.Context, order *model.Order) (WriteResult, error) {
+	start := time.Now()
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	result, err := p.saveOrder(ctx, order)
+	err = ctxErr(ctx, constraintErr(err))
+	metrics.ObserveDB("save_order", start, err)
+	return result, err
+}
+
+func (p *Postgres) saveOrder(ctx context.Context, order *model.Order) (WriteResult, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec("DELETE FROM items WHERE order_uid = $1", order.OrderUID)
-	if err != nil {
-		return fmt.Errorf("failed to delete existing items: %v", err)
+	// Строка заказа обновляется на месте, чтобы не потерять статус и его историю.
+	// Условие WHERE отсекает устаревшие версии: тогда RETURNING не вернёт строк.
+	// Заказы без версии (0) перезаписываются в порядке чтения из Kafka, но не
+	// заменяют заказ, записанный с версией
+	orderQuery := `INSERT INTO orders (
+		order_uid, track_number, entry, locale, internal_signature, 
+		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status, version
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	ON CONFLICT (order_uid) DO UPDATE SET
+		track_number = EXCLUDED.track_number, entry = EXCLUDED.entry,
+		locale = EXCLUDED.locale, internal_signature = EXCLUDED.internal_signature,
+		customer_id = EXCLUDED.customer_id, delivery_service = EXCLUDED.delivery_service,
+		shardkey = EXCLUDED.shardkey, sm_id = EXCLUDED.sm_id,
+		date_created = EXCLUDED.date_created, oof_shard = EXCLUDED.oof_shard,
+		version = EXCLUDED.version
+	WHERE orders.version < EXCLUDED.version OR (orders.version = 0 AND EXCLUDED.version = 0)
+	RETURNING status, status_version, (xmax = 0) AS inserted`
+
+	var inserted bool
+	err = tx.QueryRowContext(ctx, orderQuery,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
+		order.InternalSignature, order.CustomerID, order.DeliveryService,
+		order.Shardkey, order.SmID, order.DateCreated, order.OofShard,
+		model.StatusCreated, order.Version).Scan(&order.Status, &order.StatusVersion, &inserted)
+	if err == sql.ErrNoRows {
+		return WriteNoop, nil
 	}
-
-	_, err = tx.Exec("DELETE FROM payments WHERE order_uid = $1", order.OrderUID)
 	if err != nil {
-		return fmt.Errorf("failed to delete existing payments: %v", err)
+		return "", fmt.Errorf("failed to upsert order: %w", err)
 	}
 
-	_, err = tx.Exec("DELETE FROM deliveries WHERE order_uid = $1", order.OrderUID)
-	if err != nil {
-		return fmt.Errorf("failed to delete existing deliveries: %v", err)
+	if inserted {
+		_, err = tx.ExecContext(ctx, `INSERT INTO order_status_history (order_uid, from_status, to_status, source)
+			VALUES ($1, NULL, $2, $3)`, order.OrderUID, model.StatusCreated, StatusSourceKafka)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert status history: %w", err)
+		}
 	}
 
-	_, err = tx.Exec("DELETE FROM orders WHERE order_uid = $1", order.OrderUID)
-	if err != nil {
-		return fmt.Errorf("failed to delete existing order: %v", err)
+	if err := upsertDelivery(ctx, tx, order); err != nil {
+		return "", err
+	}
+	if err := upsertPayment(ctx, tx, order); err != nil {
+		return "", err
+	}
+	if err := syncItems(ctx, tx, order); err != nil {
+		return "", err
 	}
 
-	orderQuery := `INSERT INTO orders (
-		order_uid, track_number, entry, locale, internal_signature, 
-		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	result, eventType := WriteUpdated, model.EventOrderUpdated
+	if inserted {
+		result, eventType = WriteInserted, model.EventOrderCreated
+	}
+	if err := insertOutboxEvent(ctx, tx, eventType, order); err != nil {
+		return "", err
+	}
 
-	_, err = tx.Exec(orderQuery,
-		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
-		order.InternalSignature, order.CustomerID, order.DeliveryService,
-		order.Shardkey, order.SmID, order.DateCreated, order.OofShard)
-	if err != nil {
-		return fmt.Errorf("failed to insert order: %v", err)
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	deliveryQuery := `INSERT INTO deliveries (
-		order_uid, name, phone, zip, city, address, region, email
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	return result, nil
+}
 
-	_, err = tx.Exec(deliveryQuery,
-		order.OrderUID, order.Delivery.Name, order.Delivery.Phone,
-		order.Delivery.Zip, order.Delivery.City, order.Delivery.Address,
-		order.Delivery.Region, order.Delivery.Email)
+func upsertDelivery(ctx context.Context, tx *sql.Tx, order *model.Order) error {
+	d := order.Delivery
+	_, err := tx.ExecContext(ctx, `INSERT INTO deliveries (
+		order_uid, name, phone, zip, city, address, region, email
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (order_uid) DO UPDATE SET
+		name = EXCLUDED.name, phone = EXCLUDED.phone, zip = EXCLUDED.zip, city = EXCLUDED.city,
+		address = EXCLUDED.address, region = EXCLUDED.region, email = EXCLUDED.email`,
+		order.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email)
 	if err != nil {
-		return fmt.Errorf("failed to insert delivery: %v", err)
+		return fmt.Errorf("failed to upsert delivery: %w", err)
 	}
+	return nil
+}
 
-	paymentQuery := `INSERT INTO payments (
+func upsertPayment(ctx context.Context, tx *sql.Tx, order *model.Order) error {
+	pm := order.Payment
+	_, err := tx.ExecContext(ctx, `INSERT INTO payments (
 		order_uid, transaction, request_id, currency, provider, amount,
 		payment_dt, bank, delivery_cost, goods_total, custom_fee
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (order_uid) DO UPDATE SET
+		transaction = EXCLUDED.transaction, request_id = EXCLUDED.request_id,
+		currency = EXCLUDED.currency, provider = EXCLUDED.provider, amount = EXCLUDED.amount,
+		payment_dt = EXCLUDED.payment_dt, bank = EXCLUDED.bank, delivery_cost = EXCLUDED.delivery_cost,
+		goods_total = EXCLUDED.goods_total, custom_fee = EXCLUDED.custom_fee`,
+		order.OrderUID, pm.Transaction, pm.RequestID, pm.Currency, pm.Provider, pm.Amount,
+		pm.PaymentDt, pm.Bank, pm.DeliveryCost, pm.GoodsTotal, pm.CustomFee)
+	if err != nil {
+		return fmt.Errorf("failed to upsert payment: %w", err)
+	}
+	return nil
+}
+
+type storedItem struct {
+	id   int64
+	item model.Item
+}
 
-	_, err = tx.Exec(paymentQuery,
-		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID,
-		order.Payment.Currency, order.Payment.Provider, order.Payment.Amount,
-		order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost,
-		order.Payment.GoodsTotal, order.Payment.CustomFee)
+// syncItems приводит товары заказа к новому составу: совпадающие по chrt_id
+// строки обновляются только при изменениях, новые вставляются, лишние удаляются
+func syncItems(ctx context.Context, tx *sql.Tx, order *model.Order) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, chrt_id, track_number, price, rid, name, sale, size,
+		total_price, nm_id, brand, status FROM items WHERE order_uid = $1 ORDER BY id`, order.OrderUID)
 	if err != nil {
-		return fmt.Errorf("failed to insert payment: %v", err)
+		return fmt.Errorf("failed to get existing items: %w", err)
+	}
+
+	existing := make(map[int]storedItem)
+	for rows.Next() {
+		var s storedItem
+		if err := rows.Scan(&s.id, &s.item.ChrtID, &s.item.TrackNumber, &s.item.Price,
+			&s.item.Rid, &s.item.Name, &s.item.Sale, &s.item.Size, &s.item.TotalPrice,
+			&s.item.NmID, &s.item.Brand, &s.item.Status); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan existing item: %w", err)
+		}
+		existing[s.item.ChrtID] = s
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating existing items: %w", err)
 	}
 
 	itemQuery := `INSERT INTO items (
@@ -116,128 +246,108 @@ func (p *Postgres) SaveOrder(order *model.Order) error {
 	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
 	for _, item := range order.Items {
-		_, err = tx.Exec(itemQuery,
+		if stored, ok := existing[item.ChrtID]; ok {
+			delete(existing, item.ChrtID)
+			if stored.item == item {
+				continue
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE items SET
+				track_number = $2, price = $3, rid = $4, name = $5, sale = $6, size = $7,
+				total_price = $8, nm_id = $9, brand = $10, status = $11
+				WHERE id = $1`,
+				stored.id, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale,
+				item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
+			if err != nil {
+				return fmt.Errorf("failed to update item: %w", err)
+			}
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, itemQuery,
 			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price,
 			item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice,
 			item.NmID, item.Brand, item.Status)
 		if err != nil {
-			return fmt.Errorf("failed to insert item: %v", err)
+			return fmt.Errorf("failed to insert item: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
+	var stale []int64
+	for _, stored := range existing {
+		stale = append(stale, stored.id)
 	}
-
-	return nil
-}
-
-func (p *Postgres) GetOrderByUID(orderUID string) (*model.Order, error) {
-	var order model.Order
-
-	orderQuery := `SELECT order_uid, track_number, entry, locale, internal_signature, 
-		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard 
-		FROM orders WHERE order_uid = $1`
-
-	err := p.db.QueryRow(orderQuery, orderUID).Scan(
-		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
-		&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
-		&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+	if len(stale) > 0 {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM items WHERE id = ANY($1)", pq.Array(stale)); err != nil {
+			return fmt.Errorf("failed to delete stale items: %w", err)
 		}
-		return nil, fmt.Errorf("failed to get order: %v", err)
 	}
 
-	var delivery model.Delivery
-	deliveryQuery := `SELECT name, phone, zip, city, address, region, email 
-		FROM deliveries WHERE order_uid = $1`
+	return nil
+}
 
-	err = p.db.QueryRow(deliveryQuery, orderUID).Scan(
-		&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City,
-		&delivery.Address, &delivery.Region, &delivery.Email)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get delivery: %v", err)
+// StreamRecentOrders читает до limit самых свежих (по date_created) заказов
+// и передаёт их в fn пачками по batchSize. Каждая пачка читается одним
+// оператором на orderJSONSelect с keyset-продолжением от последнего заказа
+// предыдущей, а в памяти одновременно держится только текущая пачка. Таймаут
+// запроса действует на загрузку каждой пачки, а не на весь обход; прервать
+// его можно отменой ctx
+func (p *Postgres) StreamRecentOrders(ctx context.Context, limit, batchSize int, fn func([]model.Order) error) error {
+	if limit <= 0 {
+		return nil
 	}
-	order.Delivery = delivery
-
-	var payment model.Payment
-	paymentQuery := `SELECT transaction, request_id, currency, provider, amount,
-		payment_dt, bank, delivery_cost, goods_total, custom_fee 
-		FROM payments WHERE order_uid = $1`
-
-	err = p.db.QueryRow(paymentQuery, orderUID).Scan(
-		&payment.Transaction, &payment.RequestID, &payment.Currency,
-		&payment.Provider, &payment.Amount, &payment.PaymentDt, &payment.Bank,
-		&payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get payment: %v", err)
+	if batchSize <= 0 {
+		batchSize = 500
 	}
-	order.Payment = payment
-
-	itemsQuery := `SELECT chrt_id, track_number, price, rid, name, sale, size,
-		total_price, nm_id, brand, status FROM items WHERE order_uid = $1`
 
-	rows, err := p.db.Query(itemsQuery, orderUID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get items: %v", err)
-	}
-	defer rows.Close()
-
-	var items []model.Item
-	for rows.Next() {
-		var item model.Item
-		err := rows.Scan(
-			&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid,
-			&item.Name, &item.Sale, &item.Size, &item.TotalPrice,
-			&item.NmID, &item.Brand, &item.Status)
+	first := orderJSONSelect + "\n\tFROM orders o" + orderJSONJoins + `
+		ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $1`
+	next := orderJSONSelect + "\n\tFROM orders o" + orderJSONJoins + `
+		WHERE (o.date_created, o.order_uid) < ($2, $3)
+		ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $1`
+
+	var (
+		lastDate time.Time
+		lastUID  string
+	)
+	for read := 0; read < limit; {
+		size := min(batchSize, limit-read)
+
+		batchCtx, cancel := p.withTimeout(ctx)
+		var (
+			batch []model.Order
+			err   error
+		)
+		if read == 0 {
+			batch, err = p.queryOrders(batchCtx, size, first, size)
+		} else {
+			batch, err = p.queryOrders(batchCtx, size, next, size, lastDate, lastUID)
+		}
+		err = ctxErr(batchCtx, err)
+		cancel()
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan item: %v", err)
+			return fmt.Errorf("failed to get recent orders: %w", err)
 		}
-		items = append(items, item)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating items: %v", err)
-	}
-
-	order.Items = items
-
-	return &order, nil
-}
-
-func (p *Postgres) GetAllOrders() ([]model.Order, error) {
-	rows, err := p.db.Query("SELECT order_uid FROM orders")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get order UIDs: %v", err)
-	}
-	defer rows.Close()
-
-	var orders []model.Order
-	for rows.Next() {
-		var orderUID string
-		if err := rows.Scan(&orderUID); err != nil {
-			return nil, fmt.Errorf("failed to scan order UID: %v", err)
+		if len(batch) == 0 {
+			return nil
 		}
 
-		order, err := p.GetOrderByUID(orderUID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get order %s: %v", orderUID, err)
+		lastDate, lastUID = batch[len(batch)-1].DateCreated, batch[len(batch)-1].OrderUID
+		if err := fn(batch); err != nil {
+			return err
 		}
-		if order != nil {
-			orders = append(orders, *order)
+		read += len(batch)
+		if len(batch) < size {
+			return nil
 		}
 	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating orders: %v", err)
-	}
-
-	return orders, nil
+	return nil
 }
 
-func (p *Postgres) Exec(query string, args ...interface{}) error {
-	_, err := p.db.Exec(query, args...)
-	return err
+func (p *Postgres) Exec(ctx context.Context, query string, args ...interface{}) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, query, args...)
+	return ctxErr(ctx, err)
 }
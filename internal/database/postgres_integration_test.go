@@ -0,0 +1,527 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/model"
+	"order-service/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Интеграционные тесты работают с настоящим PostgreSQL: из TEST_POSTGRES_DSN
+// или с временным кластером, который харнесс поднимает через initdb/pg_ctl
+// из PATH (или /usr/lib/postgresql/*/bin). Если ни того ни другого нет,
+// тесты пропускаются. Перед каждым тестом таблицы очищаются, поэтому
+// TEST_POSTGRES_DSN должен указывать на отдельную базу
+var testDB struct {
+	once sync.Once
+	pg   *Postgres
+	stop func()
+	err  error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if testDB.stop != nil {
+		testDB.stop()
+	}
+	os.Exit(code)
+}
+
+func integrationDB(t testing.TB) *Postgres {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("integration test skipped in -short mode")
+	}
+
+	testDB.once.Do(func() {
+		testDB.pg, testDB.stop, testDB.err = startPostgres()
+	})
+	if testDB.err != nil {
+		t.Skipf("postgres unavailable: %v", testDB.err)
+	}
+
+	err := testDB.pg.Exec(context.Background(),
+		"TRUNCATE orders, order_status_history, outbox RESTART IDENTITY CASCADE")
+	require.NoError(t, err)
+	return testDB.pg
+}
+
+func startPostgres() (*Postgres, func(), error) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	stop := func() {}
+	if dsn == "" {
+		var err error
+		if dsn, stop, err = startCluster(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		stop()
+		return nil, nil, err
+	}
+
+	p := &Postgres{db: db}
+	p.queryTimeout.Store(int64(10 * time.Second))
+
+	list, err := LoadMigrations(migrations.FS)
+	if err == nil {
+		_, err = p.MigrateUp(ctx, list)
+	}
+	if err != nil {
+		db.Close()
+		stop()
+		return nil, nil, err
+	}
+
+	return p, func() {
+		db.Close()
+		stop()
+	}, nil
+}
+
+// startCluster создаёт кластер во временном каталоге и запускает его на
+// unix-сокете, не занимая TCP-порт
+func startCluster() (string, func(), error) {
+	bin, err := postgresBinDir()
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "order-service-pg")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+	cleanup := func() { os.RemoveAll(dir) }
+
+	initdb := exec.Command(filepath.Join(bin, "initdb"), "-D", data, "-U", "postgres", "-A", "trust", "--no-sync")
+	if out, err := initdb.CombinedOutput(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("initdb: %v: %s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	pgCtl := filepath.Join(bin, "pg_ctl")
+	start := exec.Command(pgCtl, "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-w", "start",
+		"-o", fmt.Sprintf("-p %d -k %s -c listen_addresses='' -F", port, dir))
+	if out, err := start.CombinedOutput(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("pg_ctl start: %v: %s", err, out)
+	}
+
+	stop := func() {
+		exec.Command(pgCtl, "-D", data, "-m", "immediate", "stop").Run()
+		cleanup()
+	}
+	return fmt.Sprintf("host=%s port=%d user=postgres dbname=postgres sslmode=disable", dir, port), stop, nil
+}
+
+func postgresBinDir() (string, error) {
+	if path, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(path), nil
+	}
+	matches, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+	if len(matches) == 0 {
+		return "", errors.New("initdb not found; set TEST_POSTGRES_DSN to use an existing server")
+	}
+	sort.Strings(matches)
+	return filepath.Dir(matches[len(matches)-1]), nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func integrationOrder(uid string, version int64) model.Order {
+	return model.Order{
+		OrderUID:        uid,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []model.Item{
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest",
+				Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202},
+		},
+		Version: version,
+	}
+}
+
+func outboxEvents(t *testing.T, pg *Postgres, uid string) []string {
+	t.Helper()
+	rows, err := pg.db.Query("SELECT event_type FROM outbox WHERE aggregate_id = $1 ORDER BY id", uid)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var events []string
+	for rows.Next() {
+		var event string
+		require.NoError(t, rows.Scan(&event))
+		events = append(events, event)
+	}
+	require.NoError(t, rows.Err())
+	return events
+}
+
+func TestIntegrationSaveOrderOverwriteSemantics(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	v1 := integrationOrder("order-1", 1)
+	result, err := pg.SaveOrder(ctx, &v1)
+	require.NoError(t, err)
+	assert.Equal(t, WriteInserted, result)
+
+	change, err := pg.ChangeOrderStatus(ctx, "order-1", model.StatusPaid, StatusSourceAPI)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), change.StatusVersion)
+
+	v2 := integrationOrder("order-1", 2)
+	v2.TrackNumber = "UPDATED"
+	v2.Delivery.City = "Moscow"
+	v2.Payment.Amount = 2000
+	v2.Items[0].Price = 500
+	v2.Items = append(v2.Items, model.Item{ChrtID: 1, Name: "Brush", Brand: "Other"})
+	result, err = pg.SaveOrder(ctx, &v2)
+	require.NoError(t, err)
+	assert.Equal(t, WriteUpdated, result)
+	assert.Equal(t, model.StatusPaid, v2.Status, "update must keep the current status")
+	assert.Equal(t, int64(1), v2.StatusVersion, "update must keep the status version")
+
+	for _, stale := range []int64{2, 1} {
+		order := integrationOrder("order-1", stale)
+		order.TrackNumber = "STALE"
+		result, err = pg.SaveOrder(ctx, &order)
+		require.NoError(t, err)
+		assert.Equal(t, WriteNoop, result, "version %d", stale)
+	}
+
+	got, err := pg.GetOrderByUID(ctx, "order-1")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "UPDATED", got.TrackNumber)
+	assert.Equal(t, int64(2), got.Version)
+	assert.Equal(t, model.StatusPaid, got.Status)
+	assert.Equal(t, "Moscow", got.Delivery.City)
+	assert.Equal(t, int64(2000), got.Payment.Amount)
+	assert.ElementsMatch(t, v2.Items, got.Items)
+
+	v3 := integrationOrder("order-1", 3)
+	v3.Items = v3.Items[:0]
+	_, err = pg.SaveOrder(ctx, &v3)
+	require.NoError(t, err)
+	got, err = pg.GetOrderByUID(ctx, "order-1")
+	require.NoError(t, err)
+	assert.Empty(t, got.Items, "items missing from the new version must be removed")
+
+	assert.Equal(t, []string{model.EventOrderCreated, model.EventOrderUpdated, model.EventOrderUpdated},
+		outboxEvents(t, pg, "order-1"))
+}
+
+func TestIntegrationSaveOrderWithoutVersion(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	legacy := integrationOrder("order-1", 0)
+	result, err := pg.SaveOrder(ctx, &legacy)
+	require.NoError(t, err)
+	assert.Equal(t, WriteInserted, result)
+
+	legacy.TrackNumber = "LEGACY"
+	result, err = pg.SaveOrder(ctx, &legacy)
+	require.NoError(t, err)
+	assert.Equal(t, WriteUpdated, result, "orders without version are applied in arrival order")
+
+	versioned := integrationOrder("order-1", 1)
+	result, err = pg.SaveOrder(ctx, &versioned)
+	require.NoError(t, err)
+	assert.Equal(t, WriteUpdated, result)
+
+	result, err = pg.SaveOrder(ctx, &legacy)
+	require.NoError(t, err)
+	assert.Equal(t, WriteNoop, result, "order without version must not replace a versioned one")
+}
+
+func TestIntegrationGetOrderByUIDWithMissingChildren(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	err := pg.Exec(ctx, `INSERT INTO orders (
+		order_uid, track_number, entry, locale, internal_signature,
+		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+	) VALUES ($1, $2, '', 'en', '', 'test', 'meest', '9', 99, NOW(), '1')`, "bare", "TRACK")
+	require.NoError(t, err)
+
+	got, err := pg.GetOrderByUID(ctx, "bare")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "TRACK", got.TrackNumber)
+	assert.Equal(t, model.Delivery{}, got.Delivery)
+	assert.Equal(t, model.Payment{}, got.Payment)
+	assert.Empty(t, got.Items)
+
+	missing, err := pg.GetOrderByUID(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestIntegrationSaveOrders(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	existing := integrationOrder("existing", 5)
+	_, err := pg.SaveOrder(ctx, &existing)
+	require.NoError(t, err)
+
+	newer := integrationOrder("new", 2)
+	newer.TrackNumber = "NEWER"
+	updated := integrationOrder("existing", 6)
+	updated.Items[0].Name = "Lipstick"
+	orders := []model.Order{
+		integrationOrder("new", 1),
+		newer,
+		integrationOrder("existing", 4),
+		updated,
+	}
+
+	results, err := pg.SaveOrders(ctx, orders)
+	require.NoError(t, err)
+	assert.Equal(t, []WriteResult{WriteNoop, WriteInserted, WriteNoop, WriteUpdated}, results)
+
+	got, err := pg.GetOrderByUID(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, "NEWER", got.TrackNumber)
+	assert.Equal(t, newer.Items, got.Items)
+
+	got, err = pg.GetOrderByUID(ctx, "existing")
+	require.NoError(t, err)
+	require.Len(t, got.Items, 1)
+	assert.Equal(t, "Lipstick", got.Items[0].Name)
+
+	history, err := pg.GetOrderStatusHistory(ctx, "new")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestIntegrationConstraintViolations(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	dup := integrationOrder("dup-items", 1)
+	dup.Items = append(dup.Items, dup.Items[0])
+	_, err := pg.SaveOrder(ctx, &dup)
+	var cerr *ConstraintError
+	require.ErrorAs(t, err, &cerr)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.Equal(t, "items_order_uid_chrt_id_key", cerr.Constraint)
+	assert.False(t, IsRetryable(err))
+
+	_, err = pg.SaveOrders(ctx, []model.Order{integrationOrder("ok", 1), dup})
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	negative := integrationOrder("negative", 1)
+	negative.Payment.Amount = -1
+	_, err = pg.SaveOrder(ctx, &negative)
+	assert.ErrorIs(t, err, ErrCheckViolation)
+
+	got, err := pg.GetOrderByUID(ctx, "dup-items")
+	require.NoError(t, err)
+	assert.Nil(t, got, "failed write must not leave a partial order")
+
+	valid := integrationOrder("one-to-one", 1)
+	_, err = pg.SaveOrder(ctx, &valid)
+	require.NoError(t, err)
+	err = pg.Exec(ctx, `INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email)
+		VALUES ($1, '', '', '', '', '', '', '')`, "one-to-one")
+	assert.Error(t, err, "second delivery for the same order must be rejected")
+}
+
+func TestIntegrationRelayOutbox(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	for _, uid := range []string{"a", "b"} {
+		order := integrationOrder(uid, 1)
+		_, err := pg.SaveOrder(ctx, &order)
+		require.NoError(t, err)
+	}
+
+	_, err := pg.RelayOutbox(ctx, 10, func([]model.OutboxEvent) error { return errors.New("kafka down") })
+	require.Error(t, err)
+
+	var published []string
+	n, err := pg.RelayOutbox(ctx, 10, func(events []model.OutboxEvent) error {
+		for _, e := range events {
+			published = append(published, e.AggregateID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "failed publish must leave events in the outbox")
+	assert.Equal(t, []string{"a", "b"}, published)
+
+	n, err = pg.RelayOutbox(ctx, 10, func([]model.OutboxEvent) error { return nil })
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Неопубликованные события purge не трогает
+	order := integrationOrder("c", 1)
+	_, err = pg.SaveOrder(ctx, &order)
+	require.NoError(t, err)
+
+	purged, err := pg.PurgeOutbox(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged, "recently published events must be kept")
+
+	purged, err = pg.PurgeOutbox(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	assert.Equal(t, []string{model.EventOrderCreated}, outboxEvents(t, pg, "c"))
+}
+
+func TestIntegrationChangeOrderStatusWritesOutbox(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	order := integrationOrder("order-1", 3)
+	_, err := pg.SaveOrder(ctx, &order)
+	require.NoError(t, err)
+
+	_, err = pg.ChangeOrderStatus(ctx, "order-1", model.StatusPaid, StatusSourceAPI)
+	require.NoError(t, err)
+
+	// Отклонённый переход откатывает транзакцию вместе с событием
+	_, err = pg.ChangeOrderStatus(ctx, "order-1", model.StatusCreated, StatusSourceAPI)
+	require.Error(t, err)
+
+	assert.Equal(t, []string{model.EventOrderCreated, model.EventOrderStatusChanged},
+		outboxEvents(t, pg, "order-1"))
+
+	var payload []byte
+	require.NoError(t, pg.db.QueryRow(`SELECT payload FROM outbox WHERE event_type = $1`,
+		model.EventOrderStatusChanged).Scan(&payload))
+	var event model.OrderEvent
+	require.NoError(t, json.Unmarshal(payload, &event))
+	assert.Equal(t, int64(3), event.Version)
+	assert.Nil(t, event.Order)
+	require.NotNil(t, event.StatusChange)
+	assert.Equal(t, model.StatusCreated, event.StatusChange.FromStatus)
+	assert.Equal(t, model.StatusPaid, event.StatusChange.ToStatus)
+}
+
+func TestIntegrationMigrateDownAndUp(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	list, err := LoadMigrations(migrations.FS)
+	require.NoError(t, err)
+
+	states, err := pg.MigrationStatus(ctx, list)
+	require.NoError(t, err)
+	for _, s := range states {
+		assert.NotNil(t, s.AppliedAt, "migration %03d_%s is not applied", s.Version, s.Name)
+	}
+
+	n, err := pg.MigrateDown(ctx, list, len(list))
+	require.NoError(t, err)
+	assert.Equal(t, len(list), n)
+
+	states, err = pg.MigrationStatus(ctx, list)
+	require.NoError(t, err)
+	for _, s := range states {
+		assert.Nil(t, s.AppliedAt, "migration %03d_%s is still applied", s.Version, s.Name)
+	}
+
+	n, err = pg.MigrateUp(ctx, list)
+	require.NoError(t, err)
+	assert.Equal(t, len(list), n)
+
+	n, err = pg.MigrateUp(ctx, list)
+	require.NoError(t, err)
+	assert.Zero(t, n, "second run must be a no-op")
+}
+
+func TestIntegrationMigrationStatusIsReadOnly(t *testing.T) {
+	pg := integrationDB(t)
+	ctx := context.Background()
+
+	list, err := LoadMigrations(migrations.FS)
+	require.NoError(t, err)
+
+	// Статус не ждёт миграцию, которая держит advisory lock
+	conn, err := pg.db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+	require.NoError(t, err)
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	states, err := pg.MigrationStatus(timeoutCtx, list)
+	require.NoError(t, err)
+	for _, s := range states {
+		assert.NotNil(t, s.AppliedAt, "migration %03d_%s is not applied", s.Version, s.Name)
+	}
+
+	_, err = pg.db.ExecContext(ctx, "ALTER TABLE schema_migrations RENAME TO schema_migrations_saved")
+	require.NoError(t, err)
+	defer pg.db.ExecContext(context.Background(), "ALTER TABLE schema_migrations_saved RENAME TO schema_migrations")
+
+	states, err = pg.MigrationStatus(timeoutCtx, list)
+	require.NoError(t, err)
+	for _, s := range states {
+		assert.Nil(t, s.AppliedAt, "migration %03d_%s reported as applied without schema_migrations", s.Version, s.Name)
+	}
+
+	var exists bool
+	require.NoError(t, pg.db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists))
+	assert.False(t, exists, "status must not create schema_migrations")
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-service/internal/model"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type cursor struct {
+	Sort        string    `json:"s"`
+	OrderUID    string    `json:"u"`
+	DateCreated time.Time `json:"d,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s, sort string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort || c.OrderUID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// ListOrders возвращает страницу заказов по фильтру. Пагинация keyset-ная:
+// курсор хранит ключ сортировки последнего заказа страницы, поэтому
+// глубокие страницы не требуют OFFSET
+func (p *Postgres) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	page, err := p.listOrders(ctx, filter)
+	return page, ctxErr(ctx, err)
+}
+
+// normalizeFilter подставляет сортировку и размер страницы по умолчанию
+func normalizeFilter(filter model.OrderFilter) (model.OrderFilter, error) {
+	if filter.Sort == "" {
+		filter.Sort = model.SortDateCreatedDesc
+	}
+	if !model.IsValidSort(filter.Sort) {
+		return filter, fmt.Errorf("unsupported sort %q", filter.Sort)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = model.DefaultPageLimit
+	}
+	if filter.Limit > model.MaxPageLimit {
+		filter.Limit = model.MaxPageLimit
+	}
+	return filter, nil
+}
+
+func (p *Postgres) listOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	filter, err := normalizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.CustomerID != "" {
+		addCondition("o.customer_id = $%d", filter.CustomerID)
+	}
+	if filter.TrackNumber != "" {
+		addCondition("o.track_number = $%d", filter.TrackNumber)
+	}
+	if filter.DateFrom != nil {
+		addCondition("o.date_created >= $%d", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		addCondition("o.date_created < $%d", *filter.DateTo)
+	}
+	if filter.DeliveryService != "" {
+		addCondition("o.delivery_service = $%d", filter.DeliveryService)
+	}
+	if filter.Locale != "" {
+		addCondition("o.locale = $%d", filter.Locale)
+	}
+	if filter.Brand != "" {
+		addCondition("EXISTS (SELECT 1 FROM items it WHERE it.order_uid = o.order_uid AND it.brand = $%d)", filter.Brand)
+	}
+	if filter.NmID != nil {
+		addCondition("EXISTS (SELECT 1 FROM items it WHERE it.order_uid = o.order_uid AND it.nm_id = $%d)", *filter.NmID)
+	}
+
+	direction, cmp := "ASC", ">"
+	if strings.HasPrefix(filter.Sort, "-") {
+		direction, cmp = "DESC", "<"
+	}
+	byDate := strings.TrimPrefix(filter.Sort, "-") == model.SortDateCreatedAsc
+
+	if filter.Cursor != "" {
+		c, err := decodeCursor(filter.Cursor, filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+		if byDate {
+			args = append(args, c.DateCreated, c.OrderUID)
+			conditions = append(conditions, fmt.Sprintf("(o.date_created, o.order_uid) %s ($%d, $%d)", cmp, len(args)-1, len(args)))
+		} else {
+			addCondition("o.order_uid "+cmp+" $%d", c.OrderUID)
+		}
+	}
+
+	// Страница читается одним оператором вместе с доставкой, оплатой и товарами
+	query := orderJSONSelect + "\n\tFROM orders o" + orderJSONJoins
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if byDate {
+		query += fmt.Sprintf(" ORDER BY o.date_created %s, o.order_uid %s", direction, direction)
+	} else {
+		query += fmt.Sprintf(" ORDER BY o.order_uid %s", direction)
+	}
+	args = append(args, filter.Limit+1)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	orders, err := p.queryOrders(ctx, filter.Limit+1, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+
+	page := &model.OrderPage{Orders: orders}
+	if len(orders) > filter.Limit {
+		page.Orders = orders[:filter.Limit]
+		last := page.Orders[len(page.Orders)-1]
+		page.NextCursor = encodeCursor(cursor{
+			Sort:        filter.Sort,
+			OrderUID:    last.OrderUID,
+			DateCreated: last.DateCreated,
+		})
+	}
+	return page, nil
+}
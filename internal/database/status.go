@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"order-service/internal/model"
+)
+
+const (
+	StatusSourceKafka = "kafka"
+	StatusSourceAPI   = "api"
+)
+
+var ErrOrderNotFound = errors.New("order not found")
+
+func (p *Postgres) GetOrderStatus(ctx context.Context, orderUID string) (model.OrderStatus, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var status model.OrderStatus
+	err := p.db.QueryRowContext(ctx, "SELECT status FROM orders WHERE order_uid = $1", orderUID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrOrderNotFound
+		}
+		return "", ctxErr(ctx, fmt.Errorf("failed to get order status: %w", err))
+	}
+	return status, nil
+}
+
+// ChangeOrderStatus переводит заказ в новый статус, если переход разрешён
+// state machine, и в той же транзакции записывает его в историю и outbox
+func (p *Postgres) ChangeOrderStatus(ctx context.Context, orderUID string, to model.OrderStatus, source string) (*model.StatusChange, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	change, err := p.changeOrderStatus(ctx, orderUID, to, source)
+	return change, ctxErr(ctx, constraintErr(err))
+}
+
+func (p *Postgres) changeOrderStatus(ctx context.Context, orderUID string, to model.OrderStatus, source string) (*model.StatusChange, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		from    model.OrderStatus
+		version int64
+	)
+	err = tx.QueryRowContext(ctx, "SELECT status, version FROM orders WHERE order_uid = $1 FOR UPDATE",
+		orderUID).Scan(&from, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order status: %w", err)
+	}
+
+	if !from.CanTransitionTo(to) {
+		return nil, &model.TransitionError{From: from, To: to}
+	}
+
+	change := &model.StatusChange{
+		OrderUID:   orderUID,
+		FromStatus: from,
+		ToStatus:   to,
+		Source:     source,
+	}
+	err = tx.QueryRowContext(ctx, `UPDATE orders SET status = $2, status_version = status_version + 1
+		WHERE order_uid = $1 RETURNING status_version`, orderUID, to).Scan(&change.StatusVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `INSERT INTO order_status_history (order_uid, from_status, to_status, source)
+		VALUES ($1, $2, $3, $4) RETURNING changed_at`,
+		orderUID, from, to, source).Scan(&change.ChangedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert status history: %w", err)
+	}
+
+	if err := insertStatusEvent(ctx, tx, version, change); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return change, nil
+}
+
+func (p *Postgres) GetOrderStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	history, err := p.getOrderStatusHistory(ctx, orderUID)
+	return history, ctxErr(ctx, err)
+}
+
+func (p *Postgres) getOrderStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT COALESCE(from_status, ''), to_status, source, changed_at
+		FROM order_status_history WHERE order_uid = $1 ORDER BY changed_at, id`, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []model.StatusChange{}
+	for rows.Next() {
+		change := model.StatusChange{OrderUID: orderUID}
+		if err := rows.Scan(&change.FromStatus, &change.ToStatus, &change.Source, &change.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status history: %w", err)
+		}
+		history = append(history, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating status history: %w", err)
+	}
+
+	if len(history) == 0 {
+		if _, err := p.GetOrderStatus(ctx, orderUID); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
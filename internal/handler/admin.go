@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"order-service/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminHandler — служебные эндпоинты для управления кэшем
+type AdminHandler struct {
+	orderService *service.OrderService
+}
+
+func NewAdminHandler(orderService *service.OrderService) *AdminHandler {
+	return &AdminHandler{
+		orderService: orderService,
+	}
+}
+
+// RequireToken пропускает только запросы с заголовком Authorization: Bearer <token>
+func RequireToken(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (h *AdminHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.orderService.GetCacheStats())
+}
+
+func (h *AdminHandler) InvalidateCachedOrder(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["order_uid"]
+
+	if !h.orderService.InvalidateCachedOrder(orderUID) {
+		http.Error(w, "Order not in cache", http.StatusNotFound)
+		return
+	}
+	log.Printf("Admin: order %s removed from cache", orderUID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) ReloadCache(w http.ResponseWriter, r *http.Request) {
+	loaded, err := h.orderService.ReloadCache(r.Context())
+	if err != nil {
+		if errors.Is(err, service.ErrCacheLoadInProgress) {
+			http.Error(w, "Cache reload already in progress", http.StatusConflict)
+			return
+		}
+		log.Printf("Admin: cache reload failed after %d orders: %v", loaded, err)
+		writeServerError(w, err)
+		return
+	}
+
+	log.Printf("Admin: cache reloaded with %d orders", loaded)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"loaded": loaded,
+		"stats":  h.orderService.GetCacheStats(),
+	})
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"order-service/internal/cache"
+	"order-service/internal/config"
+	"order-service/internal/database"
+	"order-service/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "0123456789abcdef"
+
+// newTestRouter собирает тот же роутер, что и main, с токеном testAdminToken
+func newTestRouter(t *testing.T, orderService *service.OrderService) http.Handler {
+	t.Helper()
+	web, err := NewWebHandler("../../templates")
+	require.NoError(t, err)
+	return NewRouter(orderService, NewHealthHandler(nil), web, testAdminToken)
+}
+
+func newAdminServer(t *testing.T) (*httptest.Server, *service.OrderService) {
+	t.Helper()
+	cfg, err := config.Load([]string{})
+	require.NoError(t, err)
+
+	repo := database.NewMemory()
+	for _, uid := range []string{"a", "b"} {
+		order := testOrder(uid, time.Now())
+		_, err := repo.SaveOrder(context.Background(), &order)
+		require.NoError(t, err)
+	}
+
+	orderService := service.NewOrderService(cfg, repo, cache.New(10), nil)
+	router := newTestRouter(t, orderService)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, orderService
+}
+
+func adminRequest(t *testing.T, method, url, token string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && v != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+	return resp.StatusCode
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	server, _ := newAdminServer(t)
+
+	for _, token := range []string{"", "wrong-token-value"} {
+		code := adminRequest(t, "GET", server.URL+"/api/admin/cache", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, code, "token %q", token)
+	}
+	assert.Equal(t, http.StatusOK, adminRequest(t, "GET", server.URL+"/api/admin/cache", testAdminToken, nil))
+}
+
+func TestRouterWithoutAdminToken(t *testing.T) {
+	cfg, err := config.Load([]string{})
+	require.NoError(t, err)
+	web, err := NewWebHandler("../../templates")
+	require.NoError(t, err)
+
+	orderService := service.NewOrderService(cfg, database.NewMemory(), cache.New(10), nil)
+	server := httptest.NewServer(NewRouter(orderService, NewHealthHandler(nil), web, ""))
+	t.Cleanup(server.Close)
+
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, "GET", server.URL+"/api/admin/cache", "", nil))
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, "POST", server.URL+"/api/order/a/status", "", nil))
+	assert.Equal(t, http.StatusOK, adminRequest(t, "GET", server.URL+"/readyz", "", nil))
+}
+
+func TestAdminCacheLifecycle(t *testing.T) {
+	server, orderService := newAdminServer(t)
+	ctx := context.Background()
+
+	var reload struct {
+		Loaded int         `json:"loaded"`
+		Stats  cache.Stats `json:"stats"`
+	}
+	code := adminRequest(t, "POST", server.URL+"/api/admin/cache/reload", testAdminToken, &reload)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, reload.Loaded)
+	assert.Equal(t, 2, reload.Stats.Size)
+	require.NotNil(t, reload.Stats.LastLoad)
+	assert.Equal(t, 2, reload.Stats.LastLoad.Orders)
+
+	_, err := orderService.GetOrder(ctx, "a")
+	require.NoError(t, err)
+
+	code = adminRequest(t, "DELETE", server.URL+"/api/admin/cache/a", testAdminToken, nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	code = adminRequest(t, "DELETE", server.URL+"/api/admin/cache/a", testAdminToken, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	_, err = orderService.GetOrder(ctx, "a")
+	require.NoError(t, err)
+
+	var stats cache.Stats
+	require.Equal(t, http.StatusOK, adminRequest(t, "GET", server.URL+"/api/admin/cache", testAdminToken, &stats))
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses, "invalidated order is read from the repository")
+	assert.Equal(t, uint64(3), stats.Insertions)
+}
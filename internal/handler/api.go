@@ -1,10 +1,18 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
+	"order-service/internal/database"
+	"order-service/internal/model"
 	"order-service/internal/service"
 
 	"github.com/gorilla/mux"
@@ -29,10 +37,10 @@ func (h *APIHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.orderService.GetOrder(orderUID)
+	order, err := h.orderService.GetOrder(r.Context(), orderUID)
 	if err != nil {
 		log.Printf("Error getting order %s: %v", orderUID, err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeServerError(w, err)
 		return
 	}
 
@@ -48,7 +56,179 @@ func (h *APIHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *APIHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+func (h *APIHandler) GetOrderStatus(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["order_uid"]
+
+	status, err := h.orderService.GetOrderStatus(r.Context(), orderUID)
+	if err != nil {
+		writeStatusError(w, orderUID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"order_uid": orderUID,
+		"status":    status,
+	})
+}
+
+func (h *APIHandler) GetOrderStatusHistory(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["order_uid"]
+
+	history, err := h.orderService.GetOrderStatusHistory(r.Context(), orderUID)
+	if err != nil {
+		writeStatusError(w, orderUID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"order_uid": orderUID,
+		"history":   history,
+	})
+}
+
+func (h *APIHandler) ChangeOrderStatus(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["order_uid"]
+
+	var req struct {
+		Status model.OrderStatus `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.Status.IsValid() {
+		http.Error(w, fmt.Sprintf("Unknown status %q", req.Status), http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.orderService.ChangeOrderStatus(r.Context(), orderUID, req.Status, database.StatusSourceAPI)
+	if err != nil {
+		writeStatusError(w, orderUID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, change)
+}
+
+func writeStatusError(w http.ResponseWriter, orderUID string, err error) {
+	var transitionErr *model.TransitionError
+	switch {
+	case errors.Is(err, database.ErrOrderNotFound):
+		http.Error(w, "Order not found", http.StatusNotFound)
+	case errors.As(err, &transitionErr):
+		http.Error(w, transitionErr.Error(), http.StatusConflict)
+	default:
+		log.Printf("Error handling status of order %s: %v", orderUID, err)
+		writeServerError(w, err)
+	}
+}
+
+// writeServerError отвечает 504, если запрос к БД не уложился в таймаут,
+// и 500 на остальные ошибки
+func writeServerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response to JSON: %v", err)
+	}
+}
+
+func (h *APIHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.orderService.ListOrders(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error listing orders: %v", err)
+		writeServerError(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		log.Printf("Error encoding orders to JSON: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func parseOrderFilter(query url.Values) (model.OrderFilter, error) {
+	filter := model.OrderFilter{
+		CustomerID:      query.Get("customer_id"),
+		TrackNumber:     query.Get("track_number"),
+		DeliveryService: query.Get("delivery_service"),
+		Locale:          query.Get("locale"),
+		Brand:           query.Get("brand"),
+		Sort:            query.Get("sort"),
+		Cursor:          query.Get("cursor"),
+	}
+
+	if filter.Sort != "" && !model.IsValidSort(filter.Sort) {
+		return filter, fmt.Errorf("invalid sort: %q", filter.Sort)
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"date_from", &filter.DateFrom},
+		{"date_to", &filter.DateTo},
+	} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, dateOnly, err := parseDate(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s: expected RFC3339 or YYYY-MM-DD", param.name)
+		}
+		// Граница date_to исключается, поэтому дата без времени включает
+		// весь указанный день
+		if dateOnly && param.dst == &filter.DateTo {
+			t = t.AddDate(0, 0, 1)
+		}
+		*param.dst = &t
+	}
+
+	if value := query.Get("nm_id"); value != "" {
+		nmID, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid nm_id: %q", value)
+		}
+		filter.NmID = &nmID
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > model.MaxPageLimit {
+			return filter, fmt.Errorf("invalid limit: must be between 1 and %d", model.MaxPageLimit)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseDate разбирает RFC3339 или дату YYYY-MM-DD (полночь UTC) и сообщает,
+// была ли это дата без времени
+func parseDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	return t, true, err
 }
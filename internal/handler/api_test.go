@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/internal/cache"
+	"order-service/internal/config"
+	"order-service/internal/database"
+	"order-service/internal/model"
+	"order-service/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, orders ...model.Order) *httptest.Server {
+	t.Helper()
+	cfg, err := config.Load([]string{})
+	require.NoError(t, err)
+
+	repo := database.NewMemory()
+	for i := range orders {
+		_, err := repo.SaveOrder(context.Background(), &orders[i])
+		require.NoError(t, err)
+	}
+
+	router := newTestRouter(t, service.NewOrderService(cfg, repo, cache.New(10), nil))
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testOrder(uid string, created time.Time) model.Order {
+	return model.Order{
+		OrderUID:    uid,
+		TrackNumber: "TRACK-" + uid,
+		CustomerID:  "customer",
+		DateCreated: created,
+		Items:       []model.Item{{ChrtID: 1, Brand: "Vivienne Sabo"}},
+		Version:     1,
+	}
+}
+
+func getJSON(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && v != nil {
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+	return resp.StatusCode
+}
+
+func postStatus(t *testing.T, url, body string) (int, map[string]interface{}) {
+	t.Helper()
+	return postStatusWithToken(t, url, testAdminToken, body)
+}
+
+func postStatusWithToken(t *testing.T, url, token, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	}
+	return resp.StatusCode, result
+}
+
+func TestGetOrder(t *testing.T) {
+	server := newTestServer(t, testOrder("a", time.Now()))
+
+	var order model.Order
+	require.Equal(t, http.StatusOK, getJSON(t, server.URL+"/api/order/a", &order))
+	assert.Equal(t, "a", order.OrderUID)
+	assert.Equal(t, model.StatusCreated, order.Status)
+
+	assert.Equal(t, http.StatusNotFound, getJSON(t, server.URL+"/api/order/missing", nil))
+}
+
+func TestChangeOrderStatus(t *testing.T) {
+	server := newTestServer(t, testOrder("a", time.Now()))
+	url := server.URL + "/api/order/a/status"
+
+	code, change := postStatus(t, url, `{"status":"paid"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "created", change["from_status"])
+	assert.Equal(t, "paid", change["to_status"])
+	assert.Equal(t, database.StatusSourceAPI, change["source"])
+
+	var status map[string]string
+	require.Equal(t, http.StatusOK, getJSON(t, url, &status))
+	assert.Equal(t, "paid", status["status"])
+
+	var history struct {
+		History []model.StatusChange `json:"history"`
+	}
+	require.Equal(t, http.StatusOK, getJSON(t, url+"/history", &history))
+	require.Len(t, history.History, 2)
+	assert.Equal(t, model.StatusPaid, history.History[1].ToStatus)
+
+	code, _ = postStatus(t, url, `{"status":"created"}`)
+	assert.Equal(t, http.StatusConflict, code, "paid -> created is not allowed")
+
+	code, _ = postStatus(t, url, `{"status":"lost"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = postStatus(t, url, `not json`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	for _, token := range []string{"", "wrong-token-value"} {
+		code, _ = postStatusWithToken(t, url, token, `{"status":"shipped"}`)
+		assert.Equal(t, http.StatusUnauthorized, code, "token %q", token)
+	}
+
+	code, _ = postStatus(t, server.URL+"/api/order/missing/status", `{"status":"paid"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, http.StatusNotFound, getJSON(t, server.URL+"/api/order/missing/status/history", nil))
+}
+
+func TestListOrdersPaginates(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var orders []model.Order
+	for i := 0; i < 5; i++ {
+		orders = append(orders, testOrder(fmt.Sprintf("order-%d", i), base.Add(time.Duration(i)*time.Hour)))
+	}
+	server := newTestServer(t, orders...)
+
+	var seen []string
+	url := server.URL + "/api/orders?limit=2&customer_id=customer"
+	for page := 0; ; page++ {
+		require.Less(t, page, 5, "pagination does not terminate")
+
+		var result model.OrderPage
+		require.Equal(t, http.StatusOK, getJSON(t, url, &result))
+		for _, order := range result.Orders {
+			seen = append(seen, order.OrderUID)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		url = server.URL + "/api/orders?limit=2&customer_id=customer&cursor=" + result.NextCursor
+	}
+
+	assert.Equal(t, []string{"order-4", "order-3", "order-2", "order-1", "order-0"}, seen)
+}
+
+func TestListOrdersDateRange(t *testing.T) {
+	server := newTestServer(t,
+		testOrder("before", time.Date(2021, 11, 25, 23, 59, 0, 0, time.UTC)),
+		testOrder("morning", time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)),
+		testOrder("evening", time.Date(2021, 11, 26, 23, 59, 59, 0, time.UTC)),
+		testOrder("after", time.Date(2021, 11, 27, 0, 0, 0, 0, time.UTC)),
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"date_from=2021-11-26&date_to=2021-11-26", []string{"evening", "morning"}},
+		{"date_to=2021-11-26", []string{"evening", "morning", "before"}},
+		{"date_from=2021-11-26", []string{"after", "evening", "morning"}},
+		{"date_to=2021-11-26T12:00:00Z", []string{"morning", "before"}},
+	}
+	for _, tt := range tests {
+		var page model.OrderPage
+		require.Equal(t, http.StatusOK, getJSON(t, server.URL+"/api/orders?"+tt.query, &page), tt.query)
+
+		var got []string
+		for _, order := range page.Orders {
+			got = append(got, order.OrderUID)
+		}
+		assert.Equal(t, tt.want, got, tt.query)
+	}
+}
+
+func TestListOrdersRejectsBadInput(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, query := range []string{
+		"limit=0",
+		"limit=1000",
+		"sort=price",
+		"date_from=yesterday",
+		"nm_id=abc",
+		"cursor=garbage",
+	} {
+		assert.Equal(t, http.StatusBadRequest, getJSON(t, server.URL+"/api/orders?"+query, nil), query)
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"order-service/internal/metrics"
+	"order-service/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewRouter собирает маршруты сервиса. Смена статуса заказа и административные
+// эндпоинты регистрируются только при непустом adminToken и закрыты им
+func NewRouter(orderService *service.OrderService, health *HealthHandler, web *WebHandler, adminToken string) *mux.Router {
+	apiHandler := NewAPIHandler(orderService)
+
+	router := mux.NewRouter()
+	router.Use(metrics.Middleware)
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/order/{order_uid}", apiHandler.GetOrder).Methods("GET")
+	api.HandleFunc("/order/{order_uid}/status", apiHandler.GetOrderStatus).Methods("GET")
+	api.HandleFunc("/order/{order_uid}/status/history", apiHandler.GetOrderStatusHistory).Methods("GET")
+	api.HandleFunc("/orders", apiHandler.ListOrders).Methods("GET")
+	api.HandleFunc("/health", health.Liveness).Methods("GET")
+	if adminToken != "" {
+		// Смена статуса меняет данные заказа, поэтому закрыта тем же токеном
+		api.Handle("/order/{order_uid}/status",
+			RequireToken(adminToken)(http.HandlerFunc(apiHandler.ChangeOrderStatus))).Methods("POST")
+
+		adminHandler := NewAdminHandler(orderService)
+		admin := api.PathPrefix("/admin").Subrouter()
+		admin.Use(RequireToken(adminToken))
+		admin.HandleFunc("/cache", adminHandler.CacheStats).Methods("GET")
+		admin.HandleFunc("/cache/reload", adminHandler.ReloadCache).Methods("POST")
+		admin.HandleFunc("/cache/{order_uid}", adminHandler.InvalidateCachedOrder).Methods("DELETE")
+	}
+	router.HandleFunc("/healthz", health.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", health.Readiness).Methods("GET")
+	router.HandleFunc("/", web.ServeIndex).Methods("GET")
+	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
+
+	return router
+}
@@ -3,81 +3,359 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"strconv"
+	"sync"
 	"time"
 
 	"order-service/internal/config"
+	"order-service/internal/metrics"
 	"order-service/internal/model"
+	"order-service/internal/resilience"
+	"order-service/internal/validation"
 
 	"github.com/segmentio/kafka-go"
 )
 
+type Message struct {
+	Order model.Order
+	raw   kafka.Message
+}
+
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// deadLetterPublisher — топик недоставленных сообщений, см. DeadLetterQueue
+type deadLetterPublisher interface {
+	Publish(ctx context.Context, m kafka.Message, stage string, cause error) error
+	Close() error
+}
+
 type Consumer struct {
-	reader      *kafka.Reader
-	messageChan chan model.Order
+	reader      messageReader
+	dlq         deadLetterPublisher
+	validator   *validation.Validator
+	messageChan chan Message
+	// dlqRetry задаёт паузы между попытками публикации в DLQ
+	dlqRetry resilience.Policy
+
+	offsets         *offsetTracker
+	commitInterval  time.Duration
+	commitBatchSize int
+	commitNow       chan struct{}
+	done            chan struct{}
+	started         bool
+
+	brokers      []string
+	dialer       *kafka.Dialer
+	maxLag       int64
+	stuckTimeout time.Duration
+
+	mu        sync.Mutex
+	lag       map[topicPartition]int64
+	lastFetch time.Time
 }
 
-func NewConsumer(cfg *config.Config) *Consumer {
+func NewConsumer(cfg *config.Config) (*Consumer, error) {
+	dialer, err := NewDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dlq, err := NewDeadLetterQueue(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	config := kafka.ReaderConfig{
-		Brokers:        cfg.Kafka.Brokers,
-		GroupID:        cfg.Kafka.GroupID,
-		Topic:          cfg.Kafka.Topic,
-		MinBytes:       10e3,
-		MaxBytes:       10e6,
-		CommitInterval: time.Second,
-		StartOffset:    kafka.FirstOffset,
+		Brokers:     cfg.Kafka.Brokers,
+		GroupID:     cfg.Kafka.GroupID,
+		Topic:       cfg.Kafka.Topic,
+		Dialer:      dialer,
+		MinBytes:    cfg.Kafka.MinBytes,
+		MaxBytes:    cfg.Kafka.MaxBytes,
+		StartOffset: kafka.FirstOffset,
+	}
+
+	var publisher deadLetterPublisher
+	if dlq != nil {
+		publisher = dlq
+	}
+
+	log.Printf("Creating Kafka consumer for topic: %s (brokers: %v)", cfg.Kafka.Topic, cfg.Kafka.Brokers)
+	consumer := newConsumer(kafka.NewReader(config), publisher,
+		cfg.Kafka.CommitInterval, cfg.Kafka.CommitBatchSize)
+	if cfg.Processing.RequireVersion {
+		consumer.validator = validation.New(append(validation.DefaultRules(), validation.PositiveVersion)...)
+	}
+	consumer.brokers = cfg.Kafka.Brokers
+	consumer.dialer = dialer
+	consumer.SetHealthLimits(cfg)
+	return consumer, nil
+}
+
+// SetHealthLimits обновляет пороги проверки готовности; безопасно вызывать на ходу
+func (c *Consumer) SetHealthLimits(cfg *config.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxLag = cfg.Kafka.MaxLag
+	c.stuckTimeout = cfg.Kafka.StuckTimeout
+}
+
+func newConsumer(reader messageReader, dlq deadLetterPublisher, commitInterval time.Duration, commitBatchSize int) *Consumer {
+	if commitInterval <= 0 {
+		commitInterval = time.Second
+	}
+	if commitBatchSize <= 0 {
+		commitBatchSize = 100
 	}
 
-	log.Printf("Creating Kafka consumer for topic: %s", cfg.Kafka.Topic)
 	return &Consumer{
-		reader:      kafka.NewReader(config),
-		messageChan: make(chan model.Order, 100),
+		reader:          reader,
+		dlq:             dlq,
+		validator:       validation.New(validation.DefaultRules()...),
+		messageChan:     make(chan Message, 100),
+		dlqRetry:        resilience.DefaultPolicy(),
+		offsets:         newOffsetTracker(),
+		commitInterval:  commitInterval,
+		commitBatchSize: commitBatchSize,
+		commitNow:       make(chan struct{}, 1),
+		done:            make(chan struct{}),
+		stuckTimeout:    time.Minute,
+		lag:             make(map[topicPartition]int64),
 	}
 }
 
 func (c *Consumer) Start(ctx context.Context) {
 	log.Println("Starting Kafka consumer...")
+	c.started = true
+	c.mu.Lock()
+	c.lastFetch = time.Now()
+	c.mu.Unlock()
 	go c.consumeMessages(ctx)
+	go c.commitLoop(ctx)
 }
 
 func (c *Consumer) consumeMessages(ctx context.Context) {
+	defer close(c.messageChan)
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Stopping Kafka consumer...")
-			c.reader.Close()
-			close(c.messageChan)
 			return
 		default:
-			m, err := c.reader.ReadMessage(ctx)
+			m, err := c.reader.FetchMessage(ctx)
 			if err != nil {
+				if ctx.Err() != nil {
+					continue
+				}
 				log.Printf("Error reading message: %v", err)
 				time.Sleep(2 * time.Second)
 				continue
 			}
+			c.offsets.track(m)
+			c.recordFetch(m)
 
 			var order model.Order
 			if err := json.Unmarshal(m.Value, &order); err != nil {
 				log.Printf("Error unmarshaling message: %v", err)
+				metrics.KafkaMessagesFailed.WithLabelValues(StageDecode).Inc()
+				if err := c.deadLetter(ctx, m, StageDecode, err); err == nil {
+					c.ack(m)
+				}
 				continue
 			}
 
-			if order.OrderUID == "" {
-				log.Printf("Invalid order: missing order_uid")
+			if errs := c.validator.Validate(&order); len(errs) > 0 {
+				log.Printf("Invalid order %q: %v", order.OrderUID, validation.Errors(errs))
+				metrics.KafkaMessagesFailed.WithLabelValues(StageValidate).Inc()
+				if err := c.deadLetter(ctx, m, StageValidate, validation.Errors(errs)); err == nil {
+					c.ack(m)
+				}
 				continue
 			}
 
 			log.Printf("Received order: %s", order.OrderUID)
-			c.messageChan <- order
+			select {
+			case c.messageChan <- Message{Order: order, raw: m}:
+			case <-ctx.Done():
+			}
 		}
 	}
 }
 
-func (c *Consumer) Messages() <-chan model.Order {
+func (c *Consumer) recordFetch(m kafka.Message) {
+	lag := m.HighWaterMark - m.Offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	metrics.KafkaConsumerLag.WithLabelValues(m.Topic, strconv.Itoa(m.Partition)).Set(float64(lag))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lag[topicPartition{topic: m.Topic, partition: m.Partition}] = lag
+	c.lastFetch = time.Now()
+}
+
+// Lag возвращает суммарное отставание по всем партициям, известное на момент
+// последнего прочитанного сообщения, и время этого чтения
+func (c *Consumer) Lag() (int64, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var total int64
+	for _, lag := range c.lag {
+		total += lag
+	}
+	return total, c.lastFetch
+}
+
+// HealthCheck проверяет доступность хотя бы одного брокера и то, что чтение
+// не зависло: при ненулевом отставании сообщения должны продолжать поступать
+func (c *Consumer) HealthCheck(ctx context.Context) error {
+	if err := c.pingBrokers(ctx); err != nil {
+		return err
+	}
+
+	lag, lastFetch := c.Lag()
+	c.mu.Lock()
+	maxLag, stuckTimeout := c.maxLag, c.stuckTimeout
+	c.mu.Unlock()
+
+	if maxLag > 0 && lag > maxLag {
+		return fmt.Errorf("consumer lag %d exceeds limit %d", lag, maxLag)
+	}
+	if c.started && lag > 0 && time.Since(lastFetch) > stuckTimeout {
+		return fmt.Errorf("consumer is stuck: lag %d, last message fetched %s ago",
+			lag, time.Since(lastFetch).Round(time.Second))
+	}
+	return nil
+}
+
+func (c *Consumer) pingBrokers(ctx context.Context) error {
+	var lastErr error
+	for _, broker := range c.brokers {
+		conn, err := c.dialer.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn.Close()
+		return nil
+	}
+	if lastErr != nil {
+		return fmt.Errorf("no kafka broker is reachable: %w", lastErr)
+	}
+	return nil
+}
+
+func (c *Consumer) Messages() <-chan Message {
 	return c.messageChan
 }
 
+// Ack подтверждает, что сообщение полностью обработано (заказ сохранён или
+// отправлен в DLQ) и его offset можно закоммитить
+func (c *Consumer) Ack(msg Message) {
+	c.ack(msg.raw)
+}
+
+func (c *Consumer) ack(m kafka.Message) {
+	if c.offsets.ack(m) >= c.commitBatchSize {
+		select {
+		case c.commitNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *Consumer) commitLoop(ctx context.Context) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.commitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			c.commit(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			c.commit(ctx)
+		case <-c.commitNow:
+			c.commit(ctx)
+		}
+	}
+}
+
+func (c *Consumer) commit(ctx context.Context) {
+	messages := c.offsets.committable()
+	if len(messages) == 0 {
+		return
+	}
+
+	if err := c.reader.CommitMessages(ctx, messages...); err != nil {
+		log.Printf("Error committing offsets: %v", err)
+		c.offsets.restore(messages)
+	}
+}
+
+// DeadLetter отправляет сообщение, которое не удалось обработать на стороне сервиса, в DLQ.
+// Ошибка означает, что публикация прервана отменой ctx; такое сообщение нельзя
+// подтверждать, оно будет прочитано повторно после перезапуска
+func (c *Consumer) DeadLetter(ctx context.Context, msg Message, stage string, cause error) error {
+	return c.deadLetter(ctx, msg.raw, stage, cause)
+}
+
+// deadLetter повторяет публикацию с нарастающей паузой, пока она не пройдёт
+// или не будет отменён ctx. Пропустить сообщение нельзя: неподтверждённый
+// offset остановил бы коммиты всей партиции
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, stage string, cause error) error {
+	if c.dlq == nil {
+		log.Printf("Dead-letter topic is not configured, dropping message %s/%d@%d",
+			m.Topic, m.Partition, m.Offset)
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.dlq.Publish(ctx, m, stage, cause)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		metrics.KafkaDLQPublishFailures.Inc()
+		backoff := c.dlqRetry.Backoff(attempt)
+		log.Printf("Error publishing message %s/%d@%d to dead-letter topic (attempt %d, retrying in %v): %v",
+			m.Topic, m.Partition, m.Offset, attempt, backoff.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
 func (c *Consumer) Close() error {
 	log.Println("Closing Kafka consumer...")
+	if c.started {
+		<-c.done
+	}
+	if c.dlq != nil {
+		if err := c.dlq.Close(); err != nil {
+			log.Printf("Error closing dead-letter producer: %v", err)
+		}
+	}
 	return c.reader.Close()
 }
@@ -0,0 +1,283 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/validation"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBroker хранит сообщения одной партиции и закоммиченный offset группы
+type fakeBroker struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	committed int64
+}
+
+func (b *fakeBroker) produce(value []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, kafka.Message{
+		Topic:     "orders",
+		Partition: 0,
+		Offset:    int64(len(b.messages)),
+		Value:     value,
+	})
+}
+
+func (b *fakeBroker) committedOffset() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.committed
+}
+
+func (b *fakeBroker) newReader() *fakeReader {
+	return &fakeReader{broker: b, pos: b.committedOffset()}
+}
+
+type fakeReader struct {
+	broker *fakeBroker
+	pos    int64
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.broker.mu.Lock()
+		if r.pos < int64(len(r.broker.messages)) {
+			m := r.broker.messages[r.pos]
+			r.pos++
+			r.broker.mu.Unlock()
+			return m, nil
+		}
+		r.broker.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+	for _, m := range msgs {
+		if m.Offset+1 > r.broker.committed {
+			r.broker.committed = m.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	return nil
+}
+
+// flakyDLQ отклоняет первые failures публикаций
+type flakyDLQ struct {
+	mu        sync.Mutex
+	failures  int
+	published []int64
+}
+
+func (q *flakyDLQ) Publish(_ context.Context, m kafka.Message, _ string, _ error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.failures > 0 {
+		q.failures--
+		return errors.New("broker unavailable")
+	}
+	q.published = append(q.published, m.Offset)
+	return nil
+}
+
+func (q *flakyDLQ) Close() error {
+	return nil
+}
+
+func (q *flakyDLQ) publishedOffsets() []int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]int64(nil), q.published...)
+}
+
+func produceOrders(t *testing.T, b *fakeBroker, n int) []string {
+	t.Helper()
+	uids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		order := generateTestOrder(i)
+		data, err := json.Marshal(order)
+		require.NoError(t, err)
+		b.produce(data)
+		uids = append(uids, order.OrderUID)
+	}
+	return uids
+}
+
+func TestConsumerAtLeastOnceAcrossCrash(t *testing.T) {
+	broker := &fakeBroker{}
+	uids := produceOrders(t, broker, 10)
+	stored := make(map[string]bool)
+
+	// Первый запуск: сохраняем четыре заказа, а на пятом процесс "падает"
+	// до записи в БД. Остальные сообщения уже прочитаны и лежат в канале
+	ctx, crash := context.WithCancel(context.Background())
+	consumer := newConsumer(broker.newReader(), nil, 10*time.Millisecond, 100)
+	consumer.Start(ctx)
+
+	for i := 0; i < 4; i++ {
+		msg := <-consumer.Messages()
+		stored[msg.Order.OrderUID] = true
+		consumer.Ack(msg)
+	}
+	<-consumer.Messages()
+
+	require.Eventually(t, func() bool { return broker.committedOffset() == 4 },
+		time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(4), broker.committedOffset(), "offset must not pass unpersisted orders")
+	crash()
+
+	// Второй запуск продолжает с закоммиченного offset
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	consumer = newConsumer(broker.newReader(), nil, 10*time.Millisecond, 100)
+	consumer.Start(ctx)
+
+	for len(stored) < len(uids) {
+		select {
+		case msg := <-consumer.Messages():
+			stored[msg.Order.OrderUID] = true
+			consumer.Ack(msg)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for redelivery, stored %d of %d orders", len(stored), len(uids))
+		}
+	}
+
+	for _, uid := range uids {
+		assert.True(t, stored[uid], "order %s was lost", uid)
+	}
+	require.Eventually(t, func() bool { return broker.committedOffset() == int64(len(uids)) },
+		time.Second, 5*time.Millisecond)
+}
+
+func TestConsumerDoesNotCommitPastPendingMessage(t *testing.T) {
+	broker := &fakeBroker{}
+	produceOrders(t, broker, 1)
+	broker.produce([]byte("not a json"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	consumer := newConsumer(broker.newReader(), nil, 10*time.Millisecond, 1)
+	consumer.Start(ctx)
+
+	msg := <-consumer.Messages()
+
+	// Битое сообщение подтверждается самим консьюмером, но коммит не должен
+	// перескочить ещё не сохранённый заказ перед ним
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(0), broker.committedOffset())
+
+	consumer.Ack(msg)
+	require.Eventually(t, func() bool { return broker.committedOffset() == 2 },
+		time.Second, 5*time.Millisecond)
+}
+
+func TestConsumerFlushesAckedOffsetsOnShutdown(t *testing.T) {
+	broker := &fakeBroker{}
+	produceOrders(t, broker, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer := newConsumer(broker.newReader(), nil, time.Hour, 100)
+	consumer.Start(ctx)
+
+	for i := 0; i < 3; i++ {
+		consumer.Ack(<-consumer.Messages())
+	}
+	cancel()
+	require.NoError(t, consumer.Close())
+
+	assert.Equal(t, int64(3), broker.committedOffset())
+}
+
+func TestConsumerRetriesDeadLetterUntilPublished(t *testing.T) {
+	broker := &fakeBroker{}
+	broker.produce([]byte("not a json"))
+	produceOrders(t, broker, 1)
+
+	dlq := &flakyDLQ{failures: 3}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	consumer := newConsumer(broker.newReader(), dlq, 10*time.Millisecond, 1)
+	consumer.dlqRetry.InitialBackoff = time.Millisecond
+	consumer.dlqRetry.MaxBackoff = 5 * time.Millisecond
+	consumer.Start(ctx)
+
+	// Сообщение за битым доставляется только после успешной публикации в DLQ
+	consumer.Ack(<-consumer.Messages())
+	assert.Equal(t, []int64{0}, dlq.publishedOffsets())
+	require.Eventually(t, func() bool { return broker.committedOffset() == 2 },
+		time.Second, 5*time.Millisecond)
+}
+
+func TestConsumerDeadLetterStopsOnCancel(t *testing.T) {
+	dlq := &flakyDLQ{failures: 1 << 30}
+	consumer := newConsumer(&fakeReader{broker: &fakeBroker{}}, dlq, time.Hour, 1)
+	consumer.dlqRetry.InitialBackoff = time.Millisecond
+	consumer.dlqRetry.MaxBackoff = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := consumer.deadLetter(ctx, kafka.Message{Offset: 7}, "decode", errors.New("bad"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, dlq.publishedOffsets())
+}
+
+func TestConsumerOrderWithoutVersion(t *testing.T) {
+	unversioned := generateTestOrder(0)
+	unversioned.Version = 0
+	data, err := json.Marshal(unversioned)
+	require.NoError(t, err)
+
+	t.Run("accepted by default", func(t *testing.T) {
+		broker := &fakeBroker{}
+		broker.produce(data)
+
+		dlq := &flakyDLQ{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		consumer := newConsumer(broker.newReader(), dlq, 10*time.Millisecond, 1)
+		consumer.Start(ctx)
+
+		msg := <-consumer.Messages()
+		assert.Equal(t, unversioned.OrderUID, msg.Order.OrderUID)
+		assert.Zero(t, msg.Order.Version)
+		assert.Empty(t, dlq.publishedOffsets())
+	})
+
+	t.Run("dead-lettered when required", func(t *testing.T) {
+		broker := &fakeBroker{}
+		broker.produce(data)
+		uids := produceOrders(t, broker, 1)
+
+		dlq := &flakyDLQ{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		consumer := newConsumer(broker.newReader(), dlq, 10*time.Millisecond, 1)
+		consumer.validator = validation.New(append(validation.DefaultRules(), validation.PositiveVersion)...)
+		consumer.Start(ctx)
+
+		msg := <-consumer.Messages()
+		assert.Equal(t, uids[0], msg.Order.OrderUID)
+		assert.Equal(t, []int64{0}, dlq.publishedOffsets(), "order without version must go to the DLQ")
+	})
+}
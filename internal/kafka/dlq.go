@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"order-service/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	StageDecode   = "decode"
+	StageValidate = "validate"
+	StagePersist  = "persist"
+)
+
+const (
+	HeaderStage             = "x-dlq-stage"
+	HeaderError             = "x-dlq-error"
+	HeaderOriginalTopic     = "x-dlq-original-topic"
+	HeaderOriginalPartition = "x-dlq-original-partition"
+	HeaderOriginalOffset    = "x-dlq-original-offset"
+	HeaderAttempt           = "x-dlq-attempt"
+	HeaderFailedAt          = "x-dlq-failed-at"
+)
+
+// DeadLetterQueue публикует сообщения, которые не удалось обработать,
+// в отдельный топик вместе с причиной ошибки
+type DeadLetterQueue struct {
+	producer *Producer
+}
+
+func NewDeadLetterQueue(cfg *config.Config) (*DeadLetterQueue, error) {
+	if cfg.Kafka.DLQTopic == "" {
+		return nil, nil
+	}
+
+	producer, err := NewProducer(cfg, cfg.Kafka.DLQTopic)
+	if err != nil {
+		return nil, err
+	}
+	// После публикации исходное сообщение подтверждается, и DLQ остаётся
+	// единственной его копией
+	producer.RequireAll()
+
+	log.Printf("Creating Kafka dead-letter producer for topic: %s", cfg.Kafka.DLQTopic)
+	return &DeadLetterQueue{producer: producer}, nil
+}
+
+func (q *DeadLetterQueue) Publish(ctx context.Context, m kafka.Message, stage string, cause error) error {
+	if q == nil {
+		return fmt.Errorf("dead-letter queue is not configured")
+	}
+
+	msg := kafka.Message{
+		Key:   m.Key,
+		Value: m.Value,
+		Headers: append(copyHeaders(m.Headers),
+			kafka.Header{Key: HeaderStage, Value: []byte(stage)},
+			kafka.Header{Key: HeaderError, Value: []byte(errorText(cause))},
+			kafka.Header{Key: HeaderOriginalTopic, Value: []byte(m.Topic)},
+			kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(m.Partition))},
+			kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+			kafka.Header{Key: HeaderAttempt, Value: []byte(strconv.Itoa(Attempt(m) + 1))},
+			kafka.Header{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+		),
+	}
+
+	if err := q.producer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to publish to dead-letter topic: %w", err)
+	}
+
+	log.Printf("Message %s/%d@%d sent to dead-letter topic %s (stage: %s)",
+		m.Topic, m.Partition, m.Offset, q.producer.Topic(), stage)
+	return nil
+}
+
+func (q *DeadLetterQueue) Close() error {
+	if q == nil {
+		return nil
+	}
+	return q.producer.Close()
+}
+
+// Attempt возвращает количество предыдущих неудачных попыток обработки,
+// записанное в заголовках сообщения при повторной отправке из DLQ
+func Attempt(m kafka.Message) int {
+	for _, h := range m.Headers {
+		if h.Key == HeaderAttempt {
+			if n, err := strconv.Atoi(string(h.Value)); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+func copyHeaders(headers []kafka.Header) []kafka.Header {
+	result := make([]kafka.Header, 0, len(headers)+7)
+	for _, h := range headers {
+		switch h.Key {
+		case HeaderStage, HeaderError, HeaderOriginalTopic, HeaderOriginalPartition,
+			HeaderOriginalOffset, HeaderAttempt, HeaderFailedAt:
+			continue
+		}
+		result = append(result, h)
+	}
+	return result
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,29 @@
+package kafka
+
+import (
+	"testing"
+
+	"order-service/internal/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterQueueRequiresAllAcks(t *testing.T) {
+	for _, acks := range []int{0, 1, -1} {
+		cfg, err := config.Load([]string{})
+		require.NoError(t, err)
+		cfg.Producer.RequiredAcks = acks
+
+		dlq, err := NewDeadLetterQueue(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, kafka.RequireAll, dlq.producer.writer.RequiredAcks, "required_acks %d", acks)
+		require.NoError(t, dlq.Close())
+
+		producer, err := NewProducer(cfg, "")
+		require.NoError(t, err)
+		assert.Equal(t, kafka.RequiredAcks(acks), producer.writer.RequiredAcks, "main producer keeps its setting")
+		require.NoError(t, producer.Close())
+	}
+}
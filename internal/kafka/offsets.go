@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+type partitionState struct {
+	pending []int64
+	acked   map[int64]kafka.Message
+	ready   *kafka.Message
+}
+
+// offsetTracker отслеживает подтверждения обработанных сообщений и отдает
+// на коммит только непрерывный префикс: если сообщение с меньшим offset
+// ещё не подтверждено, более поздние подтверждения ждут его
+type offsetTracker struct {
+	mu          sync.Mutex
+	partitions  map[topicPartition]*partitionState
+	uncommitted int
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[topicPartition]*partitionState),
+	}
+}
+
+func (t *offsetTracker) track(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{topic: m.Topic, partition: m.Partition}
+	state, ok := t.partitions[key]
+	if !ok {
+		state = &partitionState{acked: make(map[int64]kafka.Message)}
+		t.partitions[key] = state
+	}
+	state.pending = append(state.pending, m.Offset)
+}
+
+// ack помечает сообщение обработанным и возвращает число подтверждений,
+// накопленных с последнего коммита
+func (t *offsetTracker) ack(m kafka.Message) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.partitions[topicPartition{topic: m.Topic, partition: m.Partition}]
+	if !ok {
+		return t.uncommitted
+	}
+
+	state.acked[m.Offset] = m
+	for len(state.pending) > 0 {
+		next, done := state.acked[state.pending[0]]
+		if !done {
+			break
+		}
+		delete(state.acked, next.Offset)
+		state.pending = state.pending[1:]
+		state.ready = &next
+	}
+
+	t.uncommitted++
+	return t.uncommitted
+}
+
+// committable возвращает по одному сообщению на партицию — последнее из
+// непрерывно подтверждённых, — которые можно закоммитить
+func (t *offsetTracker) committable() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var messages []kafka.Message
+	for _, state := range t.partitions {
+		if state.ready != nil {
+			messages = append(messages, *state.ready)
+			state.ready = nil
+		}
+	}
+	t.uncommitted = 0
+	return messages
+}
+
+// restore возвращает сообщения, коммит которых не удался, чтобы повторить его позже
+func (t *offsetTracker) restore(messages []kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range messages {
+		state, ok := t.partitions[topicPartition{topic: m.Topic, partition: m.Partition}]
+		if !ok {
+			continue
+		}
+		if state.ready == nil || state.ready.Offset < m.Offset {
+			msg := m
+			state.ready = &msg
+		}
+	}
+}
@@ -5,94 +5,81 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
-	"time"
 
+	"order-service/internal/config"
 	"order-service/internal/model"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/compress"
 )
 
-var writer *kafka.Writer
+// Producer публикует сообщения в один топик. Безопасен для конкурентного
+// использования; можно создавать несколько экземпляров для разных топиков
+type Producer struct {
+	writer *kafka.Writer
+	topic  string
+}
 
-func InitProducer(broker string) {
-	writer = &kafka.Writer{
-		Addr:     kafka.TCP(broker),
-		Topic:    "orders",
-		Balancer: &kafka.LeastBytes{},
+// NewProducer создаёт продюсер для topic (пустой topic — основной топик
+// заказов) с брокерами, безопасностью и настройками отправки из cfg
+func NewProducer(cfg *config.Config, topic string) (*Producer, error) {
+	if topic == "" {
+		topic = cfg.Kafka.Topic
 	}
-}
 
-func SendTestData(ctx context.Context, n int) error {
-	if writer == nil {
-		return fmt.Errorf("kafka writer not initialized")
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < n; i++ {
-		order := generateTestOrder(i)
-		if err := sendMessage(ctx, order); err != nil {
-			return err
-		}
+	balancer, err := newBalancer(cfg.Producer.Balancer)
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func generateTestOrder(index int) model.Order {
-	now := time.Now()
-	orderUID := fmt.Sprintf("test_order_%d_%d", now.Unix(), index)
-
-	return model.Order{
-		OrderUID:    orderUID,
-		TrackNumber: fmt.Sprintf("WBILTESTTRACK%d", index),
-		Entry:       "WBIL",
-		Delivery: model.Delivery{
-			Name:    fmt.Sprintf("Test User %d", index),
-			Phone:   "+1234567890",
-			Zip:     fmt.Sprintf("1000%d", index),
-			City:    fmt.Sprintf("City %d", index),
-			Address: fmt.Sprintf("Test Address %d", index),
-			Region:  fmt.Sprintf("Region %d", index),
-			Email:   fmt.Sprintf("test%d@example.com", index),
-		},
-		Payment: model.Payment{
-			Transaction:  orderUID,
-			RequestID:    "",
-			Currency:     "USD",
-			Provider:     "testpay",
-			Amount:       rand.Intn(10000) + 1000,
-			PaymentDt:    now.Unix(),
-			Bank:         "testbank",
-			DeliveryCost: 500,
-			GoodsTotal:   317,
-			CustomFee:    0,
-		},
-		Items: []model.Item{
-			{
-				ChrtID:      rand.Intn(10000000),
-				TrackNumber: fmt.Sprintf("WBILTESTTRACK%d", index),
-				Price:       rand.Intn(1000) + 100,
-				Rid:         fmt.Sprintf("test_rid_%d", index),
-				Name:        fmt.Sprintf("Test Product %d", index),
-				Sale:        rand.Intn(50),
-				Size:        "M",
-				TotalPrice:  rand.Intn(900) + 100,
-				NmID:        rand.Intn(1000000),
-				Brand:       fmt.Sprintf("Test Brand %d", index),
-				Status:      202,
-			},
+	compression, err := newCompression(cfg.Producer.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Producer{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.Kafka.Brokers...),
+			Topic:                  topic,
+			Balancer:               balancer,
+			Compression:            compression,
+			RequiredAcks:           kafka.RequiredAcks(cfg.Producer.RequiredAcks),
+			BatchSize:              cfg.Producer.BatchSize,
+			BatchBytes:             cfg.Producer.BatchBytes,
+			BatchTimeout:           cfg.Producer.BatchTimeout,
+			AllowAutoTopicCreation: cfg.Producer.AllowAutoTopicCreation,
+			Transport:              transport,
 		},
-		Locale:            "en",
-		InternalSignature: "",
-		CustomerID:        fmt.Sprintf("test_customer_%d", index),
-		DeliveryService:   "test_delivery",
-		Shardkey:          fmt.Sprintf("%d", index%10),
-		SmID:              rand.Intn(100),
-		DateCreated:       now,
-		OofShard:          "1",
+		topic: topic,
+	}, nil
+}
+
+// RequireAll включает подтверждение записи всеми in-sync репликами независимо
+// от producer.required_acks. Нужен продюсерам, для которых потеря сообщения
+// недопустима; вызывать до первой отправки
+func (p *Producer) RequireAll() {
+	p.writer.RequiredAcks = kafka.RequireAll
+}
+
+func (p *Producer) Topic() string {
+	return p.topic
+}
+
+func (p *Producer) Send(ctx context.Context, msgs ...kafka.Message) error {
+	if err := p.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("error sending message to %s: %w", p.topic, err)
 	}
+	return nil
 }
 
-func sendMessage(ctx context.Context, order model.Order) error {
+// SendOrder публикует заказ в JSON с order_uid в качестве ключа, чтобы все
+// изменения одного заказа попадали в одну партицию
+func (p *Producer) SendOrder(ctx context.Context, order model.Order) error {
 	jsonData, err := json.Marshal(order)
 	if err != nil {
 		return fmt.Errorf("error marshaling order: %w", err)
@@ -102,19 +89,46 @@ func sendMessage(ctx context.Context, order model.Order) error {
 		Key:   []byte(order.OrderUID),
 		Value: jsonData,
 	}
-
-	err = writer.WriteMessages(ctx, msg)
-	if err != nil {
-		return fmt.Errorf("error sending message: %w", err)
+	if err := p.Send(ctx, msg); err != nil {
+		return err
 	}
 
 	log.Printf("Sent order: %s", order.OrderUID)
 	return nil
 }
 
-func Close() error {
-	if writer != nil {
-		return writer.Close()
+func (p *Producer) Close() error {
+	return p.writer.Close()
+}
+
+func newBalancer(name string) (kafka.Balancer, error) {
+	switch name {
+	case "", "hash":
+		return &kafka.Hash{}, nil
+	case "murmur2":
+		return kafka.Murmur2Balancer{}, nil
+	case "least_bytes":
+		return &kafka.LeastBytes{}, nil
+	case "round_robin":
+		return &kafka.RoundRobin{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported balancer %q", name)
+	}
+}
+
+func newCompression(name string) (kafka.Compression, error) {
+	switch name {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return compress.Gzip, nil
+	case "snappy":
+		return compress.Snappy, nil
+	case "lz4":
+		return compress.Lz4, nil
+	case "zstd":
+		return compress.Zstd, nil
+	default:
+		return 0, fmt.Errorf("unsupported compression %q", name)
 	}
-	return nil
 }
@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"order-service/internal/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// NewDialer возвращает dialer для kafka.Reader и проверок доступности брокеров
+// с настройками TLS и SASL из конфигурации
+func NewDialer(cfg *config.Config) (*kafka.Dialer, error) {
+	tlsConfig, mechanism, err := security(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		TLS:           tlsConfig,
+		SASLMechanism: mechanism,
+	}, nil
+}
+
+// NewTransport возвращает транспорт для kafka.Writer с теми же настройками
+func NewTransport(cfg *config.Config) (*kafka.Transport, error) {
+	tlsConfig, mechanism, err := security(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Transport{
+		TLS:  tlsConfig,
+		SASL: mechanism,
+	}, nil
+}
+
+func security(cfg *config.Config) (*tls.Config, sasl.Mechanism, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mechanism, err := newSASLMechanism(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tlsConfig, mechanism, nil
+}
+
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	t := cfg.Kafka.TLS
+	if !t.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kafka CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafka CA file %s contains no certificates", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kafka client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func newSASLMechanism(cfg *config.Config) (sasl.Mechanism, error) {
+	s := cfg.Kafka.SASL
+	switch strings.ToUpper(s.Mechanism) {
+	case "":
+		return nil, nil
+	case "PLAIN":
+		return plain.Mechanism{Username: s.Username, Password: s.Password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, s.Username, s.Password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, s.Username, s.Password)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q", s.Mechanism)
+	}
+}
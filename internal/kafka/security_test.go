@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"order-service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+func newTestCert(t *testing.T, dir, name string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(tc.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(tc.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return tc
+}
+
+// startTLSBroker поднимает TLS-заглушку брокера, которая только завершает
+// рукопожатие и сообщает, предъявил ли клиент сертификат
+func startTLSBroker(t *testing.T, ca, server *testCert) (string, <-chan int) {
+	t.Helper()
+
+	serverCert, err := tls.LoadX509KeyPair(server.certFile, server.keyFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	peers := make(chan int, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			if err := tlsConn.Handshake(); err == nil {
+				peers <- len(tlsConn.ConnectionState().PeerCertificates)
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String(), peers
+}
+
+func TestDialerTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil, true)
+	server := newTestCert(t, dir, "broker", ca, false)
+	client := newTestCert(t, dir, "client", ca, false)
+	addr, peers := startTLSBroker(t, ca, server)
+
+	t.Run("trusted CA with client certificate", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Kafka.TLS.Enabled = true
+		cfg.Kafka.TLS.CAFile = ca.certFile
+		cfg.Kafka.TLS.CertFile = client.certFile
+		cfg.Kafka.TLS.KeyFile = client.keyFile
+
+		dialer, err := NewDialer(cfg)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		require.NoError(t, err)
+		conn.Close()
+
+		assert.Equal(t, 1, <-peers, "broker should receive the client certificate")
+	})
+
+	t.Run("unknown CA", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Kafka.TLS.Enabled = true
+
+		dialer, err := NewDialer(cfg)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = dialer.DialContext(ctx, "tcp", addr)
+		require.Error(t, err)
+	})
+
+	t.Run("missing CA file", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Kafka.TLS.Enabled = true
+		cfg.Kafka.TLS.CAFile = filepath.Join(dir, "missing.crt")
+
+		_, err := NewDialer(cfg)
+		require.Error(t, err)
+	})
+}
+
+func TestSASLMechanism(t *testing.T) {
+	tests := []struct {
+		mechanism string
+		want      string
+		wantErr   bool
+	}{
+		{mechanism: "", want: ""},
+		{mechanism: "plain", want: "PLAIN"},
+		{mechanism: "SCRAM-SHA-256", want: "SCRAM-SHA-256"},
+		{mechanism: "SCRAM-SHA-512", want: "SCRAM-SHA-512"},
+		{mechanism: "GSSAPI", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mechanism, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Kafka.SASL.Mechanism = tt.mechanism
+			cfg.Kafka.SASL.Username = "user"
+			cfg.Kafka.SASL.Password = "secret"
+
+			mechanism, err := newSASLMechanism(cfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, mechanism)
+				return
+			}
+			assert.Equal(t, tt.want, mechanism.Name())
+		})
+	}
+}
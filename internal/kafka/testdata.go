@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"order-service/internal/model"
+)
+
+// SendTestData отправляет n сгенерированных заказов
+func (p *Producer) SendTestData(ctx context.Context, n int) error {
+	for i := 0; i < n; i++ {
+		if err := p.SendOrder(ctx, generateTestOrder(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func generateTestOrder(index int) model.Order {
+	now := time.Now()
+	orderUID := fmt.Sprintf("test_order_%d_%d", now.Unix(), index)
+	price := rand.Int63n(1000) + 100
+	sale := rand.Intn(50)
+	totalPrice := price * int64(100-sale) / 100
+
+	return model.Order{
+		OrderUID:    orderUID,
+		TrackNumber: fmt.Sprintf("WBILTESTTRACK%d", index),
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name:    fmt.Sprintf("Test User %d", index),
+			Phone:   "+1234567890",
+			Zip:     fmt.Sprintf("1000%d", index),
+			City:    fmt.Sprintf("City %d", index),
+			Address: fmt.Sprintf("Test Address %d", index),
+			Region:  fmt.Sprintf("Region %d", index),
+			Email:   fmt.Sprintf("test%d@example.com", index),
+		},
+		Payment: model.Payment{
+			Transaction:  orderUID,
+			RequestID:    "",
+			Currency:     "USD",
+			Provider:     "testpay",
+			Amount:       totalPrice + 500,
+			PaymentDt:    now.Unix(),
+			Bank:         "testbank",
+			DeliveryCost: 500,
+			GoodsTotal:   totalPrice,
+			CustomFee:    0,
+		},
+		Items: []model.Item{
+			{
+				ChrtID:      rand.Intn(10000000),
+				TrackNumber: fmt.Sprintf("WBILTESTTRACK%d", index),
+				Price:       price,
+				Rid:         fmt.Sprintf("test_rid_%d", index),
+				Name:        fmt.Sprintf("Test Product %d", index),
+				Sale:        sale,
+				Size:        "M",
+				TotalPrice:  totalPrice,
+				NmID:        rand.Intn(1000000),
+				Brand:       fmt.Sprintf("Test Brand %d", index),
+				Status:      202,
+			},
+		},
+		Locale:            "en",
+		InternalSignature: "",
+		CustomerID:        fmt.Sprintf("test_customer_%d", index),
+		DeliveryService:   "test_delivery",
+		Shardkey:          fmt.Sprintf("%d", index%10),
+		SmID:              rand.Intn(100),
+		DateCreated:       now,
+		OofShard:          "1",
+		Version:           1,
+	}
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "order_service"
+
+var (
+	KafkaMessagesProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_processed_total",
+		Help:      "Number of Kafka messages successfully processed.",
+	})
+
+	KafkaMessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_failed_total",
+		Help:      "Number of Kafka messages that failed processing, by stage.",
+	}, []string{"stage"})
+
+	KafkaDLQPublishFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "dlq_publish_failures_total",
+		Help:      "Number of failed attempts to publish a message to the dead-letter topic.",
+	})
+
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Number of messages between the last fetched offset and the partition high watermark.",
+	}, []string{"topic", "partition"})
+
+	OutboxEventsPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "events_published_total",
+		Help:      "Number of domain events published from the outbox.",
+	})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of database operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// ObserveDB записывает длительность операции с БД; удобно вызывать через defer
+func ObserveDB(operation string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	DBQueryDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
+
+type CacheStats struct {
+	Size        int
+	Capacity    int
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Insertions  uint64
+	Expirations uint64
+	Bytes       int64
+}
+
+// RegisterCache экспортирует статистику кэша, считывая её в момент сбора метрик
+func RegisterCache(stats func() CacheStats) {
+	gauge := func(name, help string, value func(CacheStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "cache", Name: name, Help: help,
+		}, func() float64 { return value(stats()) })
+	}
+	counter := func(name, help string, value func(CacheStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "cache", Name: name, Help: help,
+		}, func() float64 { return value(stats()) })
+	}
+
+	prometheus.MustRegister(
+		gauge("size", "Number of orders in the cache.", func(s CacheStats) float64 { return float64(s.Size) }),
+		gauge("capacity", "Maximum number of orders in the cache.", func(s CacheStats) float64 { return float64(s.Capacity) }),
+		gauge("bytes", "Estimated size of cached orders in bytes.", func(s CacheStats) float64 { return float64(s.Bytes) }),
+		counter("hits_total", "Number of cache hits.", func(s CacheStats) float64 { return float64(s.Hits) }),
+		counter("misses_total", "Number of cache misses.", func(s CacheStats) float64 { return float64(s.Misses) }),
+		counter("evictions_total", "Number of orders evicted from the cache.", func(s CacheStats) float64 { return float64(s.Evictions) }),
+		counter("insertions_total", "Number of orders added to the cache.", func(s CacheStats) float64 { return float64(s.Insertions) }),
+		counter("expirations_total", "Number of orders removed from the cache after their TTL.", func(s CacheStats) float64 { return float64(s.Expirations) }),
+	)
+}
+
+// RegisterDBStats экспортирует статистику пула соединений sql.DB
+func RegisterDBStats(stats func() sql.DBStats) {
+	gauge := func(name, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "db_pool", Name: name, Help: help,
+		}, func() float64 { return value(stats()) })
+	}
+	counter := func(name, help string, value func(sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "db_pool", Name: name, Help: help,
+		}, func() float64 { return value(stats()) })
+	}
+
+	prometheus.MustRegister(
+		gauge("max_open_connections", "Maximum number of open connections.", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		gauge("open_connections", "Number of established connections.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("in_use_connections", "Number of connections currently in use.", func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("idle_connections", "Number of idle connections.", func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		counter("wait_count_total", "Number of connections waited for.", func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("wait_duration_seconds_total", "Total time blocked waiting for a new connection.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+		counter("max_idle_closed_total", "Number of connections closed due to SetMaxIdleConns.", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }),
+		counter("max_lifetime_closed_total", "Number of connections closed due to SetConnMaxLifetime.", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }),
+	)
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	EventOrderCreated       = "OrderCreated"
+	EventOrderUpdated       = "OrderUpdated"
+	EventOrderStatusChanged = "OrderStatusChanged"
+)
+
+// OrderEvent — доменное событие, публикуемое после сохранения заказа. События
+// OrderCreated и OrderUpdated несут заказ целиком, OrderStatusChanged — только
+// StatusChange
+type OrderEvent struct {
+	Type         string        `json:"type"`
+	OrderUID     string        `json:"order_uid"`
+	Version      int64         `json:"version"`
+	OccurredAt   time.Time     `json:"occurred_at"`
+	Order        *Order        `json:"order,omitempty"`
+	StatusChange *StatusChange `json:"status_change,omitempty"`
+}
+
+// OutboxEvent — запись outbox-таблицы, ожидающая публикации
+type OutboxEvent struct {
+	ID          int64
+	AggregateID string
+	EventType   string
+	Payload     []byte
+	CreatedAt   time.Time
+}
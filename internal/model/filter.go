@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+const (
+	SortDateCreatedDesc = "-date_created"
+	SortDateCreatedAsc  = "date_created"
+	SortOrderUIDDesc    = "-order_uid"
+	SortOrderUIDAsc     = "order_uid"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// OrderFilter отбирает заказы с DateFrom <= date_created < DateTo; пустые поля
+// не ограничивают выборку
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DateFrom        *time.Time
+	DateTo          *time.Time
+	DeliveryService string
+	Locale          string
+	Brand           string
+	NmID            *int
+
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+func IsValidSort(sort string) bool {
+	switch sort {
+	case SortDateCreatedDesc, SortDateCreatedAsc, SortOrderUIDDesc, SortOrderUIDAsc:
+		return true
+	}
+	return false
+}
@@ -6,20 +6,36 @@ import (
 )
 
 type Order struct {
-	OrderUID          string    `json:"order_uid" db:"order_uid"`
-	TrackNumber       string    `json:"track_number" db:"track_number"`
-	Entry             string    `json:"entry" db:"entry"`
-	Delivery          Delivery  `json:"delivery"`
-	Payment           Payment   `json:"payment"`
-	Items             []Item    `json:"items"`
-	Locale            string    `json:"locale" db:"locale"`
-	InternalSignature string    `json:"internal_signature" db:"internal_signature"`
-	CustomerID        string    `json:"customer_id" db:"customer_id"`
-	DeliveryService   string    `json:"delivery_service" db:"delivery_service"`
-	Shardkey          string    `json:"shardkey" db:"shardkey"`
-	SmID              int       `json:"sm_id" db:"sm_id"`
-	DateCreated       time.Time `json:"date_created" db:"date_created"`
-	OofShard          string    `json:"oof_shard" db:"oof_shard"`
+	OrderUID          string      `json:"order_uid" db:"order_uid"`
+	TrackNumber       string      `json:"track_number" db:"track_number"`
+	Entry             string      `json:"entry" db:"entry"`
+	Delivery          Delivery    `json:"delivery"`
+	Payment           Payment     `json:"payment"`
+	Items             []Item      `json:"items"`
+	Locale            string      `json:"locale" db:"locale"`
+	InternalSignature string      `json:"internal_signature" db:"internal_signature"`
+	CustomerID        string      `json:"customer_id" db:"customer_id"`
+	DeliveryService   string      `json:"delivery_service" db:"delivery_service"`
+	Shardkey          string      `json:"shardkey" db:"shardkey"`
+	SmID              int         `json:"sm_id" db:"sm_id"`
+	DateCreated       time.Time   `json:"date_created" db:"date_created"`
+	OofShard          string      `json:"oof_shard" db:"oof_shard"`
+	Status            OrderStatus `json:"status,omitempty" db:"status"`
+	// Version задаёт отправитель и увеличивает с каждым изменением заказа;
+	// запись с версией не новее сохранённой игнорируется. Заказы без версии (0)
+	// применяются в порядке чтения и не заменяют заказ с версией
+	Version int64 `json:"version,omitempty" db:"version"`
+	// StatusVersion увеличивается сервисом при каждой смене статуса
+	StatusVersion int64 `json:"status_version,omitempty" db:"status_version"`
+}
+
+// NewerThan сообщает, что снимок заказа o свежее other: сначала сравнивается
+// версия отправителя, затем версия статуса
+func (o *Order) NewerThan(other *Order) bool {
+	if o.Version != other.Version {
+		return o.Version > other.Version
+	}
+	return o.StatusVersion > other.StatusVersion
 }
 
 type Delivery struct {
@@ -32,28 +48,29 @@ type Delivery struct {
 	Email   string `json:"email" db:"email"`
 }
 
+// Суммы хранятся в минимальных единицах валюты (BIGINT в БД)
 type Payment struct {
 	Transaction  string `json:"transaction" db:"transaction"`
 	RequestID    string `json:"request_id" db:"request_id"`
 	Currency     string `json:"currency" db:"currency"`
 	Provider     string `json:"provider" db:"provider"`
-	Amount       int    `json:"amount" db:"amount"`
+	Amount       int64  `json:"amount" db:"amount"`
 	PaymentDt    int64  `json:"payment_dt" db:"payment_dt"`
 	Bank         string `json:"bank" db:"bank"`
-	DeliveryCost int    `json:"delivery_cost" db:"delivery_cost"`
-	GoodsTotal   int    `json:"goods_total" db:"goods_total"`
-	CustomFee    int    `json:"custom_fee" db:"custom_fee"`
+	DeliveryCost int64  `json:"delivery_cost" db:"delivery_cost"`
+	GoodsTotal   int64  `json:"goods_total" db:"goods_total"`
+	CustomFee    int64  `json:"custom_fee" db:"custom_fee"`
 }
 
 type Item struct {
 	ChrtID      int    `json:"chrt_id" db:"chrt_id"`
 	TrackNumber string `json:"track_number" db:"track_number"`
-	Price       int    `json:"price" db:"price"`
+	Price       int64  `json:"price" db:"price"`
 	Rid         string `json:"rid" db:"rid"`
 	Name        string `json:"name" db:"name"`
 	Sale        int    `json:"sale" db:"sale"`
 	Size        string `json:"size" db:"size"`
-	TotalPrice  int    `json:"total_price" db:"total_price"`
+	TotalPrice  int64  `json:"total_price" db:"total_price"`
 	NmID        int    `json:"nm_id" db:"nm_id"`
 	Brand       string `json:"brand" db:"brand"`
 	Status      int    `json:"status" db:"status"`
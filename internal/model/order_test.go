@@ -0,0 +1,61 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderJSONRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("../../fixtures/orders/b563feb7b2b84b6test.json")
+	require.NoError(t, err)
+
+	var order Order
+	require.NoError(t, json.Unmarshal(data, &order))
+	assert.Equal(t, "b563feb7b2b84b6test", order.OrderUID)
+	require.NotEmpty(t, order.Items)
+	assert.NotZero(t, order.Payment.Amount)
+	assert.False(t, order.DateCreated.IsZero())
+
+	encoded, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	var decoded Order
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, order, decoded)
+	assert.JSONEq(t, string(data), string(encoded), "fields must keep their wire names")
+}
+
+func TestOrderJSONOmitsEmptyStatusAndVersion(t *testing.T) {
+	encoded, err := json.Marshal(Order{OrderUID: "a", DateCreated: time.Unix(0, 0).UTC()})
+	require.NoError(t, err)
+
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(encoded, &fields))
+	assert.NotContains(t, fields, "status")
+	assert.NotContains(t, fields, "version")
+
+	encoded, err = json.Marshal(Order{OrderUID: "a", Status: StatusPaid, Version: 7})
+	require.NoError(t, err)
+
+	var decoded Order
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, StatusPaid, decoded.Status)
+	assert.Equal(t, int64(7), decoded.Version)
+}
+
+func TestStatusTransitions(t *testing.T) {
+	assert.True(t, StatusCreated.CanTransitionTo(StatusPaid))
+	assert.True(t, StatusPaid.CanTransitionTo(StatusCancelled))
+	assert.True(t, StatusShipped.CanTransitionTo(StatusDelivered))
+	assert.False(t, StatusShipped.CanTransitionTo(StatusCancelled))
+	assert.False(t, StatusDelivered.CanTransitionTo(StatusCreated))
+	assert.False(t, StatusCancelled.CanTransitionTo(StatusPaid))
+
+	assert.True(t, StatusDelivered.IsValid())
+	assert.False(t, OrderStatus("lost").IsValid())
+}
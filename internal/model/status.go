@@ -0,0 +1,61 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	StatusCreated   OrderStatus = "created"
+	StatusPaid      OrderStatus = "paid"
+	StatusShipped   OrderStatus = "shipped"
+	StatusDelivered OrderStatus = "delivered"
+	StatusCancelled OrderStatus = "cancelled"
+)
+
+// transitions описывает допустимые переходы между статусами заказа.
+// delivered и cancelled — конечные статусы
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusCreated: {StatusPaid, StatusCancelled},
+	StatusPaid:    {StatusShipped, StatusCancelled},
+	StatusShipped: {StatusDelivered},
+}
+
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case StatusCreated, StatusPaid, StatusShipped, StatusDelivered, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (s OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+type TransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("transition from %q to %q is not allowed", e.From, e.To)
+}
+
+type StatusChange struct {
+	OrderUID   string      `json:"order_uid"`
+	FromStatus OrderStatus `json:"from_status,omitempty"`
+	ToStatus   OrderStatus `json:"to_status"`
+	Source     string      `json:"source"`
+	ChangedAt  time.Time   `json:"changed_at"`
+	// StatusVersion — версия статуса заказа после перехода; в историю и
+	// события не попадает
+	StatusVersion int64 `json:"-"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderStatusTransitions(t *testing.T) {
+	all := []OrderStatus{StatusCreated, StatusPaid, StatusShipped, StatusDelivered, StatusCancelled}
+	allowed := map[OrderStatus][]OrderStatus{
+		StatusCreated:   {StatusPaid, StatusCancelled},
+		StatusPaid:      {StatusShipped, StatusCancelled},
+		StatusShipped:   {StatusDelivered},
+		StatusDelivered: nil,
+		StatusCancelled: nil,
+	}
+
+	for _, from := range all {
+		for _, to := range all {
+			want := false
+			for _, s := range allowed[from] {
+				if s == to {
+					want = true
+				}
+			}
+			assert.Equal(t, want, from.CanTransitionTo(to), "%s -> %s", from, to)
+		}
+	}
+}
+
+func TestOrderStatusUnknown(t *testing.T) {
+	for _, s := range []OrderStatus{"", "lost", "PAID"} {
+		assert.False(t, s.IsValid(), "status %q", s)
+		assert.False(t, StatusCreated.CanTransitionTo(s), "created -> %q", s)
+		assert.False(t, s.CanTransitionTo(StatusPaid), "%q -> paid", s)
+	}
+	for _, s := range []OrderStatus{StatusCreated, StatusPaid, StatusShipped, StatusDelivered, StatusCancelled} {
+		assert.True(t, s.IsValid(), "status %q", s)
+	}
+}
+
+func TestTransitionErrorMessage(t *testing.T) {
+	err := &TransitionError{From: StatusDelivered, To: StatusPaid}
+	assert.Equal(t, `transition from "delivered" to "paid" is not allowed`, err.Error())
+}
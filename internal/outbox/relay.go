@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"order-service/internal/config"
+	"order-service/internal/database"
+	"order-service/internal/kafka"
+	"order-service/internal/metrics"
+	"order-service/internal/model"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderEventID   = "x-event-id"
+	HeaderEventType = "x-event-type"
+)
+
+// store — часть database.Postgres, нужная relay
+type store interface {
+	RelayOutbox(ctx context.Context, limit int, publish func([]model.OutboxEvent) error) (int, error)
+	PurgeOutbox(ctx context.Context, olderThan time.Time) (int64, error)
+}
+
+// publisher — часть kafka.Producer, нужная relay
+type publisher interface {
+	Topic() string
+	Send(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Relay публикует события из outbox в Kafka. Доставка at-least-once, а не
+// exactly-once: kafka-go не поддерживает транзакционный продюсер, поэтому если
+// процесс упадёт между записью в Kafka и коммитом транзакции, пачка уйдёт
+// повторно. Идентификатор события в заголовке x-event-id стабилен, по нему
+// подписчики отбрасывают дубликаты; с такой дедупликацией каждое событие
+// обрабатывается ровно один раз
+type Relay struct {
+	db        store
+	producer  publisher
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+}
+
+func NewRelay(cfg *config.Config, db *database.Postgres) (*Relay, error) {
+	producer, err := kafka.NewProducer(cfg, cfg.Outbox.Topic)
+	if err != nil {
+		return nil, err
+	}
+	// Событие помечается опубликованным сразу после записи, и потерянное
+	// брокером уже не отправится повторно
+	producer.RequireAll()
+
+	return &Relay{
+		db:        db,
+		producer:  producer,
+		interval:  cfg.Outbox.PollInterval,
+		batchSize: cfg.Outbox.BatchSize,
+		retention: cfg.Outbox.Retention,
+	}, nil
+}
+
+// Run опрашивает outbox до отмены ctx. Пока очередь не пуста, пачки
+// публикуются без пауз
+func (r *Relay) Run(ctx context.Context) {
+	log.Printf("Outbox relay started, publishing to %s", r.producer.Topic())
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	lastPurge := time.Now()
+	for {
+		r.drain(ctx)
+
+		if r.retention > 0 && time.Since(lastPurge) >= time.Hour {
+			r.purge(ctx, time.Now())
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain публикует пачки, пока очередь не опустеет или публикация не
+// завершится ошибкой, и возвращает число опубликованных событий
+func (r *Relay) drain(ctx context.Context) int {
+	total := 0
+	for {
+		n, err := r.db.RelayOutbox(ctx, r.batchSize, func(events []model.OutboxEvent) error {
+			return r.publish(ctx, events)
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Outbox relay error: %v", err)
+			}
+			return total
+		}
+		if n > 0 {
+			metrics.OutboxEventsPublished.Add(float64(n))
+		}
+		total += n
+		if n < r.batchSize {
+			return total
+		}
+	}
+}
+
+// purge удаляет события, опубликованные раньше now - retention
+func (r *Relay) purge(ctx context.Context, now time.Time) {
+	if n, err := r.db.PurgeOutbox(ctx, now.Add(-r.retention)); err != nil {
+		log.Printf("Outbox purge error: %v", err)
+	} else if n > 0 {
+		log.Printf("Outbox: purged %d published events", n)
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, events []model.OutboxEvent) error {
+	msgs := make([]kafkago.Message, len(events))
+	for i, e := range events {
+		msgs[i] = kafkago.Message{
+			Key:   []byte(e.AggregateID),
+			Value: e.Payload,
+			Headers: []kafkago.Header{
+				{Key: HeaderEventID, Value: []byte(strconv.FormatInt(e.ID, 10))},
+				{Key: HeaderEventType, Value: []byte(e.EventType)},
+			},
+		}
+	}
+	return r.producer.Send(ctx, msgs...)
+}
+
+func (r *Relay) Close() error {
+	return r.producer.Close()
+}
@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/model"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore повторяет семантику database.Postgres.RelayOutbox: события
+// помечаются опубликованными, только если publish вернул nil
+type fakeStore struct {
+	mu        sync.Mutex
+	events    []model.OutboxEvent
+	published map[int64]bool
+	cutoff    time.Time
+}
+
+func newFakeStore(n int) *fakeStore {
+	s := &fakeStore{published: make(map[int64]bool)}
+	for i := 1; i <= n; i++ {
+		s.events = append(s.events, model.OutboxEvent{
+			ID:          int64(i),
+			AggregateID: fmt.Sprintf("order-%d", i),
+			EventType:   model.EventOrderCreated,
+			Payload:     []byte(`{}`),
+		})
+	}
+	return s
+}
+
+func (s *fakeStore) RelayOutbox(_ context.Context, limit int, publish func([]model.OutboxEvent) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var batch []model.OutboxEvent
+	for _, e := range s.events {
+		if !s.published[e.ID] && len(batch) < limit {
+			batch = append(batch, e)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(batch); err != nil {
+		return 0, err
+	}
+	for _, e := range batch {
+		s.published[e.ID] = true
+	}
+	return len(batch), nil
+}
+
+func (s *fakeStore) PurgeOutbox(_ context.Context, olderThan time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cutoff = olderThan
+	return int64(len(s.published)), nil
+}
+
+type fakePublisher struct {
+	failures int
+	sent     []kafkago.Message
+}
+
+func (p *fakePublisher) Topic() string { return "order-events" }
+func (p *fakePublisher) Close() error  { return nil }
+
+func (p *fakePublisher) Send(_ context.Context, msgs ...kafkago.Message) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("kafka down")
+	}
+	p.sent = append(p.sent, msgs...)
+	return nil
+}
+
+func header(m kafkago.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestRelayDrainPublishesAllBatches(t *testing.T) {
+	db := newFakeStore(5)
+	producer := &fakePublisher{}
+	r := &Relay{db: db, producer: producer, batchSize: 2}
+
+	assert.Equal(t, 5, r.drain(context.Background()))
+	require.Len(t, producer.sent, 5)
+
+	first := producer.sent[0]
+	assert.Equal(t, "order-1", string(first.Key))
+	assert.Equal(t, "1", header(first, HeaderEventID))
+	assert.Equal(t, model.EventOrderCreated, header(first, HeaderEventType))
+
+	assert.Zero(t, r.drain(context.Background()), "published events must not be sent again")
+}
+
+func TestRelayKeepsEventsWhenPublishFails(t *testing.T) {
+	db := newFakeStore(3)
+	producer := &fakePublisher{failures: 1}
+	r := &Relay{db: db, producer: producer, batchSize: 10}
+
+	assert.Zero(t, r.drain(context.Background()))
+	assert.Empty(t, producer.sent)
+
+	assert.Equal(t, 3, r.drain(context.Background()))
+	ids := make([]string, len(producer.sent))
+	for i, m := range producer.sent {
+		ids[i] = header(m, HeaderEventID)
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, ids, "retried events keep their ids and order")
+}
+
+func TestRelayPurgeUsesRetention(t *testing.T) {
+	db := newFakeStore(0)
+	r := &Relay{db: db, producer: &fakePublisher{}, retention: 24 * time.Hour}
+
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	r.purge(context.Background(), now)
+	assert.Equal(t, now.Add(-24*time.Hour), db.cutoff)
+}
@@ -0,0 +1,140 @@
+package resilience
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker размыкается после FailureThreshold подряд неудачных вызовов и не
+// пропускает новые в течение OpenTimeout. Затем пропускается одна пробная
+// попытка: успех замыкает его, неудача снова размыкает
+type Breaker struct {
+	mu               sync.Mutex
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+	state            State
+	failures         int
+	openedAt         time.Time
+	probing          bool
+	now              func() time.Time
+}
+
+func NewBreaker(name string, failureThreshold int, openTimeout time.Duration) *Breaker {
+	if failureThreshold <= 0 {
+		failureThreshold = 5
+	}
+	if openTimeout <= 0 {
+		openTimeout = 10 * time.Second
+	}
+	return &Breaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// Allow сообщает, можно ли выполнить вызов прямо сейчас
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Wait блокируется, пока Breaker не пропустит вызов или не будет отменён контекст
+func (b *Breaker) Wait(ctx context.Context) error {
+	for !b.Allow() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.retryIn()):
+		}
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != StateClosed {
+		b.setState(StateClosed)
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.failureThreshold) {
+		b.openedAt = b.now()
+		b.setState(StateOpen)
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) retryIn() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		if wait := b.openTimeout - b.now().Sub(b.openedAt); wait > 0 {
+			return wait
+		}
+	}
+	return 100 * time.Millisecond
+}
+
+func (b *Breaker) setState(state State) {
+	log.Printf("Circuit breaker %s: %s -> %s", b.name, b.state, state)
+	b.state = state
+}
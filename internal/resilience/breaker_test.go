@@ -0,0 +1,89 @@
+package resilience
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBreaker возвращает Breaker с ручными часами и функцию их перевода
+func newTestBreaker(threshold int, openTimeout time.Duration) (*Breaker, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker("test", threshold, openTimeout)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Second)
+
+	b.Failure()
+	b.Failure()
+	assert.Equal(t, StateClosed, b.State())
+	assert.True(t, b.Allow())
+
+	// Успех сбрасывает счётчик подряд идущих неудач
+	b.Success()
+	b.Failure()
+	b.Failure()
+	assert.Equal(t, StateClosed, b.State())
+
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State())
+	assert.False(t, b.Allow())
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	b, advance := newTestBreaker(1, time.Second)
+	b.Failure()
+	require.Equal(t, StateOpen, b.State())
+
+	advance(999 * time.Millisecond)
+	assert.False(t, b.Allow(), "open timeout has not passed yet")
+	assert.Equal(t, time.Millisecond, b.retryIn())
+
+	advance(time.Millisecond)
+	assert.True(t, b.Allow(), "first call after the timeout is a probe")
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.False(t, b.Allow(), "only one probe at a time")
+
+	b.Success()
+	assert.Equal(t, StateClosed, b.State())
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+}
+
+func TestBreakerFailedProbeReopens(t *testing.T) {
+	b, advance := newTestBreaker(2, time.Second)
+	b.Failure()
+	b.Failure()
+	advance(time.Second)
+	require.True(t, b.Allow())
+
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State(), "failed probe opens the breaker regardless of threshold")
+	assert.False(t, b.Allow())
+	assert.Equal(t, time.Second, b.retryIn(), "open timeout restarts from the failed probe")
+}
+
+func TestBreakerWaitHonoursContext(t *testing.T) {
+	b, _ := newTestBreaker(1, time.Hour)
+	b.Failure()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.Wait(ctx), context.DeadlineExceeded)
+
+	closed, _ := newTestBreaker(1, time.Hour)
+	assert.NoError(t, closed.Wait(context.Background()))
+}
+
+func TestBreakerDefaults(t *testing.T) {
+	b := NewBreaker("test", 0, 0)
+	assert.Equal(t, 5, b.failureThreshold)
+	assert.Equal(t, 10*time.Second, b.openTimeout)
+	assert.Equal(t, "half-open", StateHalfOpen.String())
+}
@@ -0,0 +1,75 @@
+package resilience
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+type Policy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter — доля задержки (0..1), на которую она случайно уменьшается,
+	// чтобы реплики не ретраили синхронно
+	Jitter float64
+	// Retryable решает, имеет ли смысл повторять операцию после ошибки
+	Retryable func(error) bool
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+}
+
+// Do выполняет fn, повторяя её при retryable-ошибках, пока не исчерпаны попытки
+// или не отменён контекст. Возвращается последняя ошибка
+func (p Policy) Do(ctx context.Context, fn func() error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= maxAttempts || p.Retryable == nil || !p.Retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff возвращает задержку перед попыткой номер attempt+1
+func (p Policy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		backoff -= backoff * jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
@@ -0,0 +1,117 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTemporary = errors.New("temporary")
+
+func TestBackoffGrowsAndIsCapped(t *testing.T) {
+	p := Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		assert.Equal(t, w*time.Millisecond, p.Backoff(i+1), "attempt %d", i+1)
+	}
+	assert.Equal(t, time.Second, p.Backoff(10_000), "huge attempt numbers stay capped")
+}
+
+func TestBackoffMultiplierBelowOneIsConstant(t *testing.T) {
+	p := Policy{InitialBackoff: 50 * time.Millisecond, Multiplier: 0.5}
+	assert.Equal(t, 50*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 50*time.Millisecond, p.Backoff(5))
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	tests := []struct {
+		jitter float64
+		min    time.Duration
+	}{
+		{0.5, 500 * time.Millisecond},
+		{1, 0},
+		{3, 0}, // доля больше 1 ограничивается единицей
+	}
+
+	for _, tt := range tests {
+		p := Policy{InitialBackoff: time.Second, MaxBackoff: time.Second, Multiplier: 2, Jitter: tt.jitter}
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 200; i++ {
+			d := p.Backoff(3)
+			assert.GreaterOrEqual(t, d, tt.min, "jitter %v", tt.jitter)
+			assert.LessOrEqual(t, d, time.Second, "jitter %v", tt.jitter)
+			seen[d] = true
+		}
+		assert.Greater(t, len(seen), 1, "jitter %v must randomize the delay", tt.jitter)
+	}
+}
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	p := Policy{MaxAttempts: 5, InitialBackoff: time.Millisecond, Retryable: func(error) bool { return true }}
+
+	calls := 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errTemporary
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDoStopsAfterMaxAttempts(t *testing.T) {
+	p := Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Retryable: func(error) bool { return true }}
+
+	calls := 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return errTemporary
+	})
+	assert.ErrorIs(t, err, errTemporary)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDoDoesNotRetryPermanentErrors(t *testing.T) {
+	permanent := errors.New("permanent")
+	tests := []struct {
+		name   string
+		policy Policy
+	}{
+		{"not retryable", Policy{MaxAttempts: 5, Retryable: func(err error) bool { return err == errTemporary }}},
+		{"no classifier", Policy{MaxAttempts: 5}},
+		{"zero attempts", Policy{Retryable: func(error) bool { return true }}},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		err := tt.policy.Do(context.Background(), func() error {
+			calls++
+			return permanent
+		})
+		assert.ErrorIs(t, err, permanent, tt.name)
+		assert.Equal(t, 1, calls, tt.name)
+	}
+}
+
+func TestDoHonoursCancellation(t *testing.T) {
+	p := Policy{MaxAttempts: 100, InitialBackoff: time.Hour, Retryable: func(error) bool { return true }}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	calls := 0
+	err := p.Do(ctx, func() error {
+		calls++
+		return errTemporary
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls, "no attempts after cancellation")
+	assert.Less(t, time.Since(start), time.Second, "backoff wait must be interrupted")
+}
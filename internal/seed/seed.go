@@ -0,0 +1,91 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"order-service/internal/config"
+	"order-service/internal/kafka"
+	"order-service/internal/model"
+	"order-service/internal/validation"
+)
+
+// Run публикует демонстрационные заказы в основной топик: из каталога
+// фикстур, если он задан, иначе cfg.Seed.Count сгенерированных заказов.
+// Вызывается только при включённом cfg.Seed.Enabled
+func Run(ctx context.Context, cfg *config.Config, producer *kafka.Producer) error {
+	if cfg.Seed.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(cfg.Seed.Delay):
+		}
+	}
+
+	if cfg.Seed.FixturesDir == "" {
+		log.Printf("Seeding %d generated orders...", cfg.Seed.Count)
+		return producer.SendTestData(ctx, cfg.Seed.Count)
+	}
+
+	orders, err := LoadFixtures(cfg.Seed.FixturesDir)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Seeding %d orders from %s...", len(orders), cfg.Seed.FixturesDir)
+	for _, order := range orders {
+		if err := producer.SendOrder(ctx, order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFixtures читает все *.json файлы каталога. Файл может содержать один
+// заказ или массив заказов; каждый заказ проверяется валидатором
+func LoadFixtures(dir string) ([]model.Order, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.json fixtures found in %s", dir)
+	}
+	sort.Strings(files)
+
+	var orders []model.Order
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", file, err)
+		}
+
+		var batch []model.Order
+		if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+			err = json.Unmarshal(data, &batch)
+		} else {
+			var order model.Order
+			err = json.Unmarshal(data, &order)
+			batch = []model.Order{order}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", file, err)
+		}
+
+		for i := range batch {
+			if errs := validation.Validate(&batch[i]); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid order %q in fixture %s: %w",
+					batch[i].OrderUID, file, validation.Errors(errs))
+			}
+		}
+		orders = append(orders, batch...)
+	}
+	return orders, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"order-service/internal/cache"
+	"order-service/internal/database"
+	"order-service/internal/kafka"
+	"order-service/internal/model"
+)
+
+// OrderRepository — постоянное хранилище заказов. Реализации: database.Postgres
+// и database.Memory
+type OrderRepository interface {
+	SaveOrder(ctx context.Context, order *model.Order) (database.WriteResult, error)
+	SaveOrders(ctx context.Context, orders []model.Order) ([]database.WriteResult, error)
+	GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error)
+	GetOrdersByUIDs(ctx context.Context, uids []string) ([]model.Order, error)
+	StreamRecentOrders(ctx context.Context, limit, batchSize int, fn func([]model.Order) error) error
+	ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error)
+	GetOrderStatus(ctx context.Context, orderUID string) (model.OrderStatus, error)
+	GetOrderStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error)
+	ChangeOrderStatus(ctx context.Context, orderUID string, status model.OrderStatus, source string) (*model.StatusChange, error)
+}
+
+// OrderCache — кэш заказов перед хранилищем
+type OrderCache interface {
+	Get(orderUID string) (model.Order, bool)
+	Peek(orderUID string) (model.Order, bool)
+	Set(order model.Order)
+	Add(order model.Order) bool
+	SetStatus(orderUID string, status model.OrderStatus, statusVersion int64) bool
+	Append(orders []model.Order) int
+	Rebuild(fill func(add func([]model.Order) int) error) (int, error)
+	Delete(orderUID string) bool
+	Capacity() int
+	Resize(capacity int)
+	Stats() cache.Stats
+	RecordLoad(orders int, took time.Duration)
+}
+
+// OrderSource — поток входящих заказов с подтверждением обработки
+type OrderSource interface {
+	Start(ctx context.Context)
+	Messages() <-chan kafka.Message
+	Ack(msg kafka.Message)
+	DeadLetter(ctx context.Context, msg kafka.Message, stage string, cause error) error
+}
+
+var (
+	_ OrderRepository = (*database.Postgres)(nil)
+	_ OrderRepository = (*database.Memory)(nil)
+	_ OrderCache      = (*cache.Cache)(nil)
+	_ OrderSource     = (*kafka.Consumer)(nil)
+)
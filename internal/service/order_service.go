@@ -2,91 +2,354 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sync/atomic"
+	"time"
 
 	"order-service/internal/cache"
+	"order-service/internal/config"
 	"order-service/internal/database"
 	"order-service/internal/kafka"
+	"order-service/internal/metrics"
 	"order-service/internal/model"
+	"order-service/internal/resilience"
 )
 
 type OrderService struct {
-	db       *database.Postgres
-	cache    *cache.Cache
-	consumer *kafka.Consumer
+	db       OrderRepository
+	cache    OrderCache
+	consumer OrderSource
+
+	retry   atomic.Pointer[resilience.Policy]
+	breaker *resilience.Breaker
+
+	workers     int
+	queueSize   int
+	batchSize   int
+	batchWindow time.Duration
+
+	warmUpBatchSize int
+	warmUpRetry     resilience.Policy
+	warmUpErr       atomic.Pointer[error]
+	cacheWarmedUp   atomic.Bool
+	cacheLoading    atomic.Bool
 }
 
-func NewOrderService(db *database.Postgres, consumer *kafka.Consumer) *OrderService {
-	service := &OrderService{
-		db:       db,
-		cache:    cache.New(1000), // Кэш на 1000 записей
-		consumer: consumer,
-	}
+// ErrCacheLoadInProgress — кэш уже загружается из БД
+var ErrCacheLoadInProgress = errors.New("cache load already in progress")
 
-	if err := service.loadCacheFromDB(); err != nil {
-		log.Printf("Warning: failed to load cache from DB: %v", err)
+func NewOrderService(cfg *config.Config, db OrderRepository, cache OrderCache, consumer OrderSource) *OrderService {
+	service := &OrderService{
+		db:              db,
+		cache:           cache,
+		consumer:        consumer,
+		breaker:         resilience.NewBreaker("postgres", cfg.Database.BreakerThreshold, cfg.Database.BreakerOpenTimeout),
+		workers:         cfg.Processing.Workers,
+		queueSize:       cfg.Processing.QueueSize,
+		batchSize:       cfg.Processing.BatchSize,
+		batchWindow:     cfg.Processing.BatchWindow,
+		warmUpBatchSize: cfg.Cache.WarmUpBatchSize,
+		warmUpRetry:     resilience.DefaultPolicy(),
 	}
+	service.setRetryPolicy(cfg)
 
 	return service
 }
 
-func (s *OrderService) loadCacheFromDB() error {
-	orders, err := s.db.GetAllOrders()
+// Reconfigure применяет параметры, которые можно менять без перезапуска
+func (s *OrderService) Reconfigure(cfg *config.Config) {
+	s.setRetryPolicy(cfg)
+	s.cache.Resize(cfg.Cache.Capacity)
+}
+
+func (s *OrderService) setRetryPolicy(cfg *config.Config) {
+	retry := resilience.DefaultPolicy()
+	retry.MaxAttempts = cfg.Database.RetryMaxAttempts
+	retry.InitialBackoff = cfg.Database.RetryInitialBackoff
+	retry.MaxBackoff = cfg.Database.RetryMaxBackoff
+	retry.Retryable = database.IsRetryable
+	s.retry.Store(&retry)
+}
+
+// loadCacheFromDB загружает в кэш самые свежие заказы. Без rebuild заказы
+// дописываются в кэш по мере чтения (прогрев при старте), с rebuild — содержимое
+// кэша заменяется целиком после успешного чтения. Одновременно выполняется
+// только одна загрузка
+func (s *OrderService) loadCacheFromDB(ctx context.Context, rebuild bool) (int, error) {
+	if !s.cacheLoading.CompareAndSwap(false, true) {
+		return 0, ErrCacheLoadInProgress
+	}
+	defer s.cacheLoading.Store(false)
+
+	stream := func(add func([]model.Order) int) error {
+		return s.db.StreamRecentOrders(ctx, s.cache.Capacity(), s.warmUpBatchSize, func(orders []model.Order) error {
+			add(orders)
+			return nil
+		})
+	}
+
+	start := time.Now()
+	var (
+		loaded int
+		err    error
+	)
+	if rebuild {
+		loaded, err = s.cache.Rebuild(stream)
+	} else {
+		err = stream(func(orders []model.Order) int {
+			n := s.cache.Append(orders)
+			loaded += n
+			return n
+		})
+	}
 	if err != nil {
-		return err
+		return loaded, err
 	}
 
-	s.cache.LoadFromOrders(orders)
-	log.Printf("Loaded %d orders into cache", len(orders))
-	return nil
+	took := time.Since(start)
+	s.cache.RecordLoad(loaded, took)
+	log.Printf("Loaded %d orders into cache in %v", loaded, took)
+	return loaded, nil
 }
 
 func (s *OrderService) Start(ctx context.Context) {
+	go s.warmUpCache(ctx)
 	s.consumer.Start(ctx)
 	go s.processMessages(ctx)
 }
 
+// warmUpCache повторяет начальную загрузку кэша с нарастающей задержкой, пока
+// она не пройдёт успешно или не будет отменён контекст. Уже загруженные заказы
+// при повторе пропускаются
+func (s *OrderService) warmUpCache(ctx context.Context) {
+	for attempt := 1; ; attempt++ {
+		_, err := s.loadCacheFromDB(ctx, false)
+		if err == nil {
+			s.warmUpErr.Store(nil)
+			s.cacheWarmedUp.Store(true)
+			return
+		}
+		s.warmUpErr.Store(&err)
+		log.Printf("Warning: failed to load cache from DB (attempt %d): %v", attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.warmUpRetry.Backoff(attempt)):
+		}
+	}
+}
+
+// CacheHealthCheck сообщает, завершилась ли начальная загрузка кэша; если
+// последняя попытка не удалась, возвращается её ошибка
+func (s *OrderService) CacheHealthCheck(ctx context.Context) error {
+	if s.cacheWarmedUp.Load() {
+		return nil
+	}
+	if err := s.warmUpErr.Load(); err != nil {
+		return fmt.Errorf("cache warm-up failed: %w", *err)
+	}
+	return errors.New("cache warm-up in progress")
+}
+
+// processMessages раздаёт сообщения пулу воркеров по order_uid: порядок
+// изменений одного заказа сохраняется, разные заказы пишутся параллельно.
+// Подтверждения приходят не по порядку, но консьюмер коммитит только
+// непрерывный префикс обработанных offset'ов
 func (s *OrderService) processMessages(ctx context.Context) {
+	pool := newKeyedPool(s.workers, s.queueSize, s.batchSize, s.batchWindow, func(batch []kafka.Message) {
+		s.processBatch(ctx, batch)
+	})
+	defer pool.Close()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case order, ok := <-s.consumer.Messages():
+		case msg, ok := <-s.consumer.Messages():
 			if !ok {
 				return
 			}
-			s.processOrder(order)
+			if !pool.Submit(ctx, msg.Order.OrderUID, msg) {
+				return
+			}
 		}
 	}
 }
 
-func (s *OrderService) processOrder(order model.Order) {
-	if err := s.db.SaveOrder(&order); err != nil {
+// processBatch записывает пачку заказов одной транзакцией. При неисправимой
+// ошибке заказы пишутся по одному, чтобы в DLQ попали только проблемные
+func (s *OrderService) processBatch(ctx context.Context, batch []kafka.Message) {
+	if len(batch) == 1 {
+		s.processMessage(ctx, batch[0])
+		return
+	}
+
+	orders := make([]model.Order, len(batch))
+	for i, msg := range batch {
+		orders[i] = msg.Order
+	}
+
+	var results []database.WriteResult
+	err := s.persist(ctx, fmt.Sprintf("batch of %d orders", len(orders)), func() error {
+		var err error
+		results, err = s.db.SaveOrders(ctx, orders)
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Error saving batch of %d orders, falling back to single writes: %v", len(batch), err)
+		for _, msg := range batch {
+			s.processMessage(ctx, msg)
+		}
+		return
+	}
+
+	written := 0
+	for i, msg := range batch {
+		s.consumer.Ack(msg)
+		metrics.KafkaMessagesProcessed.Inc()
+		if results[i] != database.WriteNoop {
+			s.cache.Set(orders[i])
+			written++
+		}
+	}
+	log.Printf("Processed batch of %d orders, %d written", len(batch), written)
+}
+
+func (s *OrderService) processMessage(ctx context.Context, msg kafka.Message) {
+	order := msg.Order
+	result, err := s.saveOrder(ctx, &order)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Сообщение не подтверждено и будет прочитано повторно после перезапуска
+			return
+		}
 		log.Printf("Error saving order to database: %v", err)
+		metrics.KafkaMessagesFailed.WithLabelValues(kafka.StagePersist).Inc()
+		if err := s.consumer.DeadLetter(ctx, msg, kafka.StagePersist, err); err == nil {
+			s.consumer.Ack(msg)
+		}
+		return
+	}
+	s.consumer.Ack(msg)
+	metrics.KafkaMessagesProcessed.Inc()
+
+	if result == database.WriteNoop {
+		log.Printf("Skipped stale or duplicate order: %s (version %d)", order.OrderUID, order.Version)
 		return
 	}
 
 	s.cache.Set(order)
-	log.Printf("Processed and cached order: %s", order.OrderUID)
+	log.Printf("Processed and cached order: %s (%s)", order.OrderUID, result)
+}
+
+func (s *OrderService) saveOrder(ctx context.Context, order *model.Order) (database.WriteResult, error) {
+	var result database.WriteResult
+	err := s.persist(ctx, "order "+order.OrderUID, func() error {
+		var err error
+		result, err = s.db.SaveOrder(ctx, order)
+		return err
+	})
+	return result, err
 }
 
-func (s *OrderService) GetOrder(orderUID string) (*model.Order, error) {
+// persist повторяет запись при временных ошибках БД. Пока БД недоступна,
+// circuit breaker разомкнут и обработка (а значит и чтение из Kafka) стоит
+// на паузе. Таймауты и конфликты повторяются не больше RetryMaxAttempts раз:
+// запрос, который не укладывается в таймаут, иначе занял бы воркер навсегда.
+// Такой заказ, как и заказ с неисправимой ошибкой, уходит в DLQ
+func (s *OrderService) persist(ctx context.Context, what string, write func() error) error {
+	for {
+		err := s.retry.Load().Do(ctx, func() error {
+			if err := s.breaker.Wait(ctx); err != nil {
+				return err
+			}
+
+			// Размыкать breaker стоит только при недоступной БД: медленный
+			// запрос не повод останавливать запись остальных заказов
+			err := write()
+			if database.IsConnectionError(err) {
+				s.breaker.Failure()
+			} else {
+				s.breaker.Success()
+			}
+			return err
+		})
+		if err == nil || !database.IsConnectionError(err) {
+			return err
+		}
+
+		log.Printf("Database unavailable, retries for %s exhausted: %v", what, err)
+	}
+}
+
+func (s *OrderService) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
 	if cachedOrder, exists := s.cache.Get(orderUID); exists {
 		return &cachedOrder, nil
 	}
 
-	order, err := s.db.GetOrderByUID(orderUID)
+	order, err := s.db.GetOrderByUID(ctx, orderUID)
 	if err != nil {
 		return nil, err
 	}
 
 	if order != nil {
-		s.cache.Set(*order)
+		s.cache.Add(*order)
 	}
 
 	return order, nil
 }
 
-func (s *OrderService) GetCacheStats() (int, int) {
-	return s.cache.Size(), 0
+func (s *OrderService) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	return s.db.ListOrders(ctx, filter)
+}
+
+func (s *OrderService) GetOrderStatus(ctx context.Context, orderUID string) (model.OrderStatus, error) {
+	return s.db.GetOrderStatus(ctx, orderUID)
+}
+
+func (s *OrderService) GetOrderStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	return s.db.GetOrderStatusHistory(ctx, orderUID)
+}
+
+func (s *OrderService) ChangeOrderStatus(ctx context.Context, orderUID string, status model.OrderStatus, source string) (*model.StatusChange, error) {
+	change, err := s.db.ChangeOrderStatus(ctx, orderUID, status, source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Заказа нет в кэше, но параллельный GetOrder мог прочитать его до смены
+	// статуса и закэшировать после неё. Свежий снимок из БД новее по
+	// status_version, поэтому Set вытеснит устаревший, а Add его не заменит
+	if !s.cache.SetStatus(orderUID, change.ToStatus, change.StatusVersion) {
+		order, err := s.db.GetOrderByUID(ctx, orderUID)
+		switch {
+		case err != nil:
+			log.Printf("Warning: failed to reload order %s after status change: %v", orderUID, err)
+			s.cache.Delete(orderUID)
+		case order != nil:
+			s.cache.Set(*order)
+		}
+	}
+	return change, nil
+}
+
+func (s *OrderService) GetCacheStats() cache.Stats {
+	return s.cache.Stats()
+}
+
+// InvalidateCachedOrder убирает заказ из кэша; следующее чтение пойдёт в БД
+func (s *OrderService) InvalidateCachedOrder(orderUID string) bool {
+	return s.cache.Delete(orderUID)
+}
+
+// ReloadCache заново загружает свежие заказы из БД и подменяет ими содержимое
+// кэша. Пока идёт загрузка, кэш отвечает по-старому; при ошибке он не меняется
+func (s *OrderService) ReloadCache(ctx context.Context) (int, error) {
+	return s.loadCacheFromDB(ctx, true)
 }
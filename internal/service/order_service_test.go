@@ -0,0 +1,528 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/internal/cache"
+	"order-service/internal/config"
+	"order-service/internal/database"
+	"order-service/internal/kafka"
+	"order-service/internal/model"
+	"order-service/internal/resilience"
+)
+
+type fakeSource struct {
+	messages chan kafka.Message
+
+	mu    sync.Mutex
+	acked []string
+	dead  []string
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{messages: make(chan kafka.Message, 10)}
+}
+
+func (f *fakeSource) Start(context.Context)          {}
+func (f *fakeSource) Messages() <-chan kafka.Message { return f.messages }
+
+func (f *fakeSource) Ack(msg kafka.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.acked = append(f.acked, msg.Order.OrderUID)
+}
+
+func (f *fakeSource) DeadLetter(_ context.Context, msg kafka.Message, _ string, _ error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dead = append(f.dead, msg.Order.OrderUID)
+	return nil
+}
+
+func (f *fakeSource) ackedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.acked)
+}
+
+func newTestService(t *testing.T) (*OrderService, *database.Memory, *fakeSource) {
+	t.Helper()
+	cfg, err := config.Load([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := database.NewMemory()
+	source := newFakeSource()
+	return NewOrderService(cfg, repo, cache.New(10), source), repo, source
+}
+
+func testOrder(uid string, version int64) model.Order {
+	return model.Order{
+		OrderUID:    uid,
+		TrackNumber: "TRACK-" + uid,
+		DateCreated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Items:       []model.Item{{ChrtID: 1, Price: 100, TotalPrice: 100}},
+		Version:     version,
+	}
+}
+
+func TestProcessMessageStoresAndCaches(t *testing.T) {
+	s, repo, source := newTestService(t)
+	ctx := context.Background()
+
+	s.processMessage(ctx, kafka.Message{Order: testOrder("a", 1)})
+
+	stored, err := repo.GetOrderByUID(ctx, "a")
+	if err != nil || stored == nil {
+		t.Fatalf("order not stored: %v", err)
+	}
+	if stored.Status != model.StatusCreated {
+		t.Fatalf("status = %q, want %q", stored.Status, model.StatusCreated)
+	}
+	if _, ok := s.cache.Peek("a"); !ok {
+		t.Fatal("order not cached")
+	}
+	if source.ackedCount() != 1 {
+		t.Fatalf("acked %d messages, want 1", source.ackedCount())
+	}
+}
+
+func TestProcessMessageSkipsStaleVersion(t *testing.T) {
+	s, _, source := newTestService(t)
+	ctx := context.Background()
+
+	fresh := testOrder("a", 2)
+	fresh.TrackNumber = "FRESH"
+	s.processMessage(ctx, kafka.Message{Order: fresh})
+	s.processMessage(ctx, kafka.Message{Order: testOrder("a", 1)})
+
+	order, err := s.GetOrder(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.TrackNumber != "FRESH" || order.Version != 2 {
+		t.Fatalf("stale version overwrote order: %+v", order)
+	}
+	if source.ackedCount() != 2 {
+		t.Fatalf("acked %d messages, want 2", source.ackedCount())
+	}
+}
+
+func TestProcessMessageWithoutVersion(t *testing.T) {
+	s, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	first := testOrder("a", 0)
+	s.processMessage(ctx, kafka.Message{Order: first})
+	second := testOrder("a", 0)
+	second.TrackNumber = "SECOND"
+	s.processMessage(ctx, kafka.Message{Order: second})
+
+	order, err := s.GetOrder(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.TrackNumber != "SECOND" {
+		t.Fatalf("later order without version was not applied: %+v", order)
+	}
+
+	s.processMessage(ctx, kafka.Message{Order: testOrder("a", 1)})
+	s.processMessage(ctx, kafka.Message{Order: second})
+	order, err = s.GetOrder(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Version != 1 {
+		t.Fatalf("order without version replaced a versioned one: %+v", order)
+	}
+}
+
+func TestProcessBatchKeepsLatestVersion(t *testing.T) {
+	s, repo, source := newTestService(t)
+	ctx := context.Background()
+
+	newer := testOrder("a", 3)
+	newer.TrackNumber = "NEWER"
+	s.processBatch(ctx, []kafka.Message{
+		{Order: testOrder("a", 1)},
+		{Order: newer},
+		{Order: testOrder("b", 1)},
+	})
+
+	stored, _ := repo.GetOrderByUID(ctx, "a")
+	if stored == nil || stored.TrackNumber != "NEWER" {
+		t.Fatalf("got %+v, want the newest version of a", stored)
+	}
+	if cached, _ := s.cache.Peek("a"); cached.TrackNumber != "NEWER" {
+		t.Fatalf("cached %+v, want the newest version of a", cached)
+	}
+	if source.ackedCount() != 3 {
+		t.Fatalf("acked %d messages, want 3", source.ackedCount())
+	}
+}
+
+// slowRepository имитирует запрос, который никогда не укладывается в таймаут
+// (как blockingDriver в пакете database), для заказов из slow
+type slowRepository struct {
+	*database.Memory
+	slow  map[string]bool
+	calls atomic.Int32
+}
+
+func (r *slowRepository) timeout(ctx context.Context) error {
+	r.calls.Add(1)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	return fmt.Errorf("failed to save order: %w", ctx.Err())
+}
+
+func (r *slowRepository) SaveOrder(ctx context.Context, order *model.Order) (database.WriteResult, error) {
+	if r.slow[order.OrderUID] {
+		return database.WriteNoop, r.timeout(ctx)
+	}
+	return r.Memory.SaveOrder(ctx, order)
+}
+
+func (r *slowRepository) SaveOrders(ctx context.Context, orders []model.Order) ([]database.WriteResult, error) {
+	for _, order := range orders {
+		if r.slow[order.OrderUID] {
+			return nil, r.timeout(ctx)
+		}
+	}
+	return r.Memory.SaveOrders(ctx, orders)
+}
+
+func TestProcessBatchDeadLettersTimedOutOrder(t *testing.T) {
+	cfg, err := config.Load([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Database.RetryMaxAttempts = 3
+	cfg.Database.RetryInitialBackoff = time.Millisecond
+	cfg.Database.RetryMaxBackoff = time.Millisecond
+	cfg.Database.BreakerThreshold = 2
+
+	repo := &slowRepository{Memory: database.NewMemory(), slow: map[string]bool{"slow": true}}
+	source := newFakeSource()
+	s := NewOrderService(cfg, repo, cache.New(10), source)
+
+	done := make(chan struct{})
+	go func() {
+		s.processBatch(context.Background(), []kafka.Message{
+			{Order: testOrder("a", 1)},
+			{Order: testOrder("slow", 1)},
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker is stuck retrying a query that always times out")
+	}
+
+	if source.ackedCount() != 2 {
+		t.Fatalf("acked %d messages, want 2", source.ackedCount())
+	}
+	if len(source.dead) != 1 || source.dead[0] != "slow" {
+		t.Fatalf("dead-lettered %v, want [slow]", source.dead)
+	}
+	if stored, _ := repo.GetOrderByUID(context.Background(), "a"); stored == nil {
+		t.Fatal("healthy order from the batch was not stored")
+	}
+	// пачка и одиночная запись, по RetryMaxAttempts попыток каждая
+	if calls := repo.calls.Load(); calls != 6 {
+		t.Fatalf("slow order written %d times, want 6", calls)
+	}
+	if state := s.breaker.State(); state != resilience.StateClosed {
+		t.Fatalf("breaker is %s after query timeouts, want closed", state)
+	}
+}
+
+// brokenStreamRepository обрывает чтение заказов после первой пачки
+type brokenStreamRepository struct {
+	*database.Memory
+}
+
+func (r brokenStreamRepository) StreamRecentOrders(ctx context.Context, limit, batchSize int, fn func([]model.Order) error) error {
+	if err := fn([]model.Order{testOrder("partial", 1)}); err != nil {
+		return err
+	}
+	return errors.New("connection reset")
+}
+
+func TestReloadCacheKeepsContentsOnFailure(t *testing.T) {
+	cfg, err := config.Load([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewOrderService(cfg, brokenStreamRepository{database.NewMemory()}, cache.New(10), newFakeSource())
+	s.cache.Set(testOrder("a", 1))
+
+	if _, err := s.ReloadCache(context.Background()); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if _, ok := s.cache.Peek("a"); !ok {
+		t.Fatal("failed reload dropped cached order")
+	}
+	if _, ok := s.cache.Peek("partial"); ok {
+		t.Fatal("failed reload left partially loaded orders in the cache")
+	}
+}
+
+// unavailableRepository отказывает в чтении заказов, пока down не сброшен
+type unavailableRepository struct {
+	*database.Memory
+	down atomic.Bool
+}
+
+func (r *unavailableRepository) StreamRecentOrders(ctx context.Context, limit, batchSize int, fn func([]model.Order) error) error {
+	if r.down.Load() {
+		return errors.New("connection refused")
+	}
+	return r.Memory.StreamRecentOrders(ctx, limit, batchSize, fn)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWarmUpCacheRetriesUntilLoaded(t *testing.T) {
+	cfg, err := config.Load([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &unavailableRepository{Memory: database.NewMemory()}
+	repo.down.Store(true)
+	order := testOrder("a", 1)
+	if _, err := repo.SaveOrder(context.Background(), &order); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewOrderService(cfg, repo, cache.New(10), newFakeSource())
+	s.warmUpRetry.InitialBackoff = time.Millisecond
+	s.warmUpRetry.MaxBackoff = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.warmUpCache(ctx)
+
+	waitFor(t, func() bool {
+		err := s.CacheHealthCheck(ctx)
+		return err != nil && strings.Contains(err.Error(), "connection refused")
+	})
+	if _, ok := s.cache.Peek("a"); ok {
+		t.Fatal("order cached while the repository is down")
+	}
+
+	repo.down.Store(false)
+	waitFor(t, func() bool { return s.CacheHealthCheck(ctx) == nil })
+	if _, ok := s.cache.Peek("a"); !ok {
+		t.Fatal("order not cached after warm-up")
+	}
+}
+
+func TestGetOrderFallsBackToRepository(t *testing.T) {
+	s, repo, _ := newTestService(t)
+	ctx := context.Background()
+
+	order := testOrder("a", 1)
+	if _, err := repo.SaveOrder(ctx, &order); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetOrder(ctx, "a")
+	if err != nil || got == nil {
+		t.Fatalf("GetOrder = %v, %v", got, err)
+	}
+	if _, ok := s.cache.Peek("a"); !ok {
+		t.Fatal("order loaded from repository was not cached")
+	}
+
+	missing, err := s.GetOrder(ctx, "missing")
+	if err != nil || missing != nil {
+		t.Fatalf("GetOrder(missing) = %v, %v; want nil, nil", missing, err)
+	}
+}
+
+// pausedReadRepository при первом чтении заказа ждёт release, прежде чем
+// вернуть его, как запрос, который отстал от параллельной записи
+type pausedReadRepository struct {
+	*database.Memory
+	paused  atomic.Bool
+	read    chan struct{}
+	release chan struct{}
+}
+
+func newPausedReadRepository() *pausedReadRepository {
+	return &pausedReadRepository{Memory: database.NewMemory(), read: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (r *pausedReadRepository) GetOrderByUID(ctx context.Context, orderUID string) (*model.Order, error) {
+	order, err := r.Memory.GetOrderByUID(ctx, orderUID)
+	if r.paused.CompareAndSwap(false, true) {
+		close(r.read)
+		<-r.release
+	}
+	return order, err
+}
+
+func TestGetOrderDoesNotCacheStaleSnapshot(t *testing.T) {
+	cfg, err := config.Load([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newPausedReadRepository()
+	s := NewOrderService(cfg, repo, cache.New(10), newFakeSource())
+	ctx := context.Background()
+
+	old := testOrder("a", 1)
+	if _, err := repo.SaveOrder(ctx, &old); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := s.GetOrder(ctx, "a"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	<-repo.read
+	s.processMessage(ctx, kafka.Message{Order: testOrder("a", 2)})
+	close(repo.release)
+	<-done
+
+	cached, ok := s.cache.Peek("a")
+	if !ok || cached.Version != 2 {
+		t.Fatalf("cached order = %+v, %v; want version 2", cached, ok)
+	}
+}
+
+func TestChangeOrderStatusDuringCacheMiss(t *testing.T) {
+	cfg, err := config.Load([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newPausedReadRepository()
+	s := NewOrderService(cfg, repo, cache.New(10), newFakeSource())
+	ctx := context.Background()
+
+	order := testOrder("a", 1)
+	if _, err := repo.SaveOrder(ctx, &order); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := s.GetOrder(ctx, "a"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	<-repo.read
+	if _, err := s.ChangeOrderStatus(ctx, "a", model.StatusPaid, database.StatusSourceAPI); err != nil {
+		t.Fatal(err)
+	}
+	close(repo.release)
+	<-done
+
+	cached, ok := s.cache.Peek("a")
+	if !ok || cached.Status != model.StatusPaid {
+		t.Fatalf("cached order = %+v, %v; want status %s", cached, ok, model.StatusPaid)
+	}
+}
+
+func TestChangeOrderStatusUpdatesCache(t *testing.T) {
+	s, _, _ := newTestService(t)
+	ctx := context.Background()
+	s.processMessage(ctx, kafka.Message{Order: testOrder("a", 1)})
+
+	if _, err := s.ChangeOrderStatus(ctx, "a", model.StatusPaid, database.StatusSourceAPI); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := s.cache.Peek("a"); cached.Status != model.StatusPaid {
+		t.Fatalf("cached status = %q, want %q", cached.Status, model.StatusPaid)
+	}
+
+	_, err := s.ChangeOrderStatus(ctx, "a", model.StatusCreated, database.StatusSourceAPI)
+	var transitionErr *model.TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("err = %v, want TransitionError", err)
+	}
+}
+
+func TestChangeOrderStatusLifecycle(t *testing.T) {
+	s, _, _ := newTestService(t)
+	ctx := context.Background()
+	s.processMessage(ctx, kafka.Message{Order: testOrder("a", 1)})
+
+	for _, status := range []model.OrderStatus{model.StatusPaid, model.StatusShipped, model.StatusDelivered} {
+		if _, err := s.ChangeOrderStatus(ctx, "a", status, database.StatusSourceAPI); err != nil {
+			t.Fatalf("-> %s: %v", status, err)
+		}
+	}
+
+	// delivered — конечный статус
+	_, err := s.ChangeOrderStatus(ctx, "a", model.StatusCancelled, database.StatusSourceAPI)
+	var transitionErr *model.TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("err = %v, want TransitionError", err)
+	}
+
+	history, err := s.GetOrderStatusHistory(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 || history[3].ToStatus != model.StatusDelivered {
+		t.Fatalf("history = %+v, want created, paid, shipped, delivered", history)
+	}
+
+	_, err = s.ChangeOrderStatus(ctx, "missing", model.StatusPaid, database.StatusSourceAPI)
+	if !errors.Is(err, database.ErrOrderNotFound) {
+		t.Fatalf("err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestStartConsumesSource(t *testing.T) {
+	s, repo, source := newTestService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.Start(ctx)
+	for _, uid := range []string{"a", "b", "c"} {
+		source.messages <- kafka.Message{Order: testOrder(uid, 1)}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for source.ackedCount() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("acked %d of 3 messages", source.ackedCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	page, err := repo.ListOrders(ctx, model.OrderFilter{Sort: model.SortOrderUIDAsc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Orders) != 3 {
+		t.Fatalf("stored %d orders, want 3", len(page.Orders))
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// keyedPool распределяет задачи по воркерам по хэшу ключа: задачи с одним
+// ключом выполняются строго по очереди одним воркером, с разными — параллельно.
+// Очереди воркеров ограничены, поэтому при заполнении Submit блокируется и
+// чтение из Kafka притормаживает вместе с обработкой.
+//
+// Воркер передаёт задачи в handle пачками до batchSize штук: забирает всё,
+// что уже есть в очереди, и ждёт остальное не дольше batchWindow
+type keyedPool[T any] struct {
+	queues      []chan T
+	handle      func([]T)
+	batchSize   int
+	batchWindow time.Duration
+	wg          sync.WaitGroup
+}
+
+func newKeyedPool[T any](workers, queueSize, batchSize int, batchWindow time.Duration, handle func([]T)) *keyedPool[T] {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	p := &keyedPool[T]{
+		queues:      make([]chan T, workers),
+		handle:      handle,
+		batchSize:   batchSize,
+		batchWindow: batchWindow,
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan T, queueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *keyedPool[T]) work(queue <-chan T) {
+	defer p.wg.Done()
+	for task := range queue {
+		batch, open := p.collect(queue, task)
+		p.handle(batch)
+		if !open {
+			return
+		}
+	}
+}
+
+// collect добирает пачку после первой задачи. Второе значение false, если
+// очередь закрыта
+func (p *keyedPool[T]) collect(queue <-chan T, first T) ([]T, bool) {
+	batch := []T{first}
+	if p.batchSize == 1 {
+		return batch, true
+	}
+
+	var deadline <-chan time.Time
+	if p.batchWindow > 0 {
+		timer := time.NewTimer(p.batchWindow)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for len(batch) < p.batchSize {
+		select {
+		case task, ok := <-queue:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, task)
+			continue
+		default:
+		}
+		if deadline == nil {
+			break
+		}
+
+		select {
+		case task, ok := <-queue:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, task)
+		case <-deadline:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// Submit ставит задачу в очередь воркера, отвечающего за key. Возвращает
+// false, если ctx отменён раньше, чем в очереди освободилось место
+func (p *keyedPool[T]) Submit(ctx context.Context, key string, task T) bool {
+	select {
+	case p.queues[p.slot(key)] <- task:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *keyedPool[T]) slot(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// Close дожидается обработки уже поставленных задач и останавливает воркеров.
+// После Close вызывать Submit нельзя
+func (p *keyedPool[T]) Close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
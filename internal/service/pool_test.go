@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/internal/config"
+	"order-service/internal/database"
+	"order-service/internal/model"
+	"order-service/internal/seed"
+)
+
+type task struct {
+	key string
+	seq int
+}
+
+func TestKeyedPoolPreservesPerKeyOrder(t *testing.T) {
+	const keys, perKey = 20, 50
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+	)
+	pool := newKeyedPool(4, 2, 8, time.Millisecond, func(batch []task) {
+		mu.Lock()
+		for _, tk := range batch {
+			seen[tk.key] = append(seen[tk.key], tk.seq)
+		}
+		mu.Unlock()
+	})
+
+	for seq := 0; seq < perKey; seq++ {
+		for k := 0; k < keys; k++ {
+			key := fmt.Sprintf("order-%d", k)
+			if !pool.Submit(context.Background(), key, task{key: key, seq: seq}) {
+				t.Fatal("submit failed")
+			}
+		}
+	}
+	pool.Close()
+
+	for key, seqs := range seen {
+		if len(seqs) != perKey {
+			t.Fatalf("%s: got %d tasks, want %d", key, len(seqs), perKey)
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Fatalf("%s: task %d processed at position %d", key, seq, i)
+			}
+		}
+	}
+}
+
+func TestKeyedPoolSubmitBlocksWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	pool := newKeyedPool(1, 1, 1, 0, func([]struct{}) { <-release })
+	defer pool.Close()
+	defer close(release)
+
+	ctx := context.Background()
+	pool.Submit(ctx, "a", struct{}{}) // занимает воркера
+	pool.Submit(ctx, "a", struct{}{}) // занимает очередь
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if pool.Submit(ctx, "a", struct{}{}) {
+		t.Fatal("submit to a full queue must block until ctx is done")
+	}
+}
+
+func TestKeyedPoolCollectsBatches(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches []int
+	)
+	pool := newKeyedPool(1, 10, 4, 50*time.Millisecond, func(batch []int) {
+		mu.Lock()
+		batches = append(batches, len(batch))
+		mu.Unlock()
+	})
+	for i := 0; i < 10; i++ {
+		pool.Submit(context.Background(), "order", i)
+	}
+	pool.Close()
+
+	total := 0
+	for _, n := range batches {
+		if n > 4 {
+			t.Fatalf("batch of %d exceeds batch size", n)
+		}
+		total += n
+	}
+	if total != 10 || len(batches) > 4 {
+		t.Fatalf("got batches %v, want 10 tasks in at most 4 batches", batches)
+	}
+}
+
+// BenchmarkKeyedPool моделирует запись в БД задержкой в 1ms
+func BenchmarkKeyedPool(b *testing.B) {
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			pool := newKeyedPool(workers, 16, 1, 0, func([]int) { time.Sleep(time.Millisecond) })
+			for i := 0; i < b.N; i++ {
+				pool.Submit(context.Background(), fmt.Sprintf("order-%d", i), i)
+			}
+			pool.Close()
+		})
+	}
+}
+
+// BenchmarkSaveOrderPool пишет заказы в локальный PostgreSQL (параметры из
+// DB_* переменных, схема из migrations/). Без доступной БД пропускается:
+//
+//	DB_HOST=localhost DB_PASSWORD=... go test -run=^$ -bench=SaveOrderPool ./internal/service
+func BenchmarkSaveOrderPool(b *testing.B) {
+	if os.Getenv("DB_HOST") == "" {
+		b.Skip("DB_HOST is not set")
+	}
+	cfg, err := config.Load(nil)
+	if err != nil {
+		b.Skip(err)
+	}
+	db, err := database.NewPostgres(cfg)
+	if err != nil {
+		b.Skipf("postgres unavailable: %v", err)
+	}
+	defer db.Close()
+
+	fixtures, err := seed.LoadFixtures("../../fixtures/orders")
+	if err != nil {
+		b.Fatal(err)
+	}
+	template := fixtures[0]
+
+	run := fmt.Sprintf("bench%d", time.Now().UnixNano())
+	cases := []struct{ workers, batchSize int }{{1, 1}, {4, 1}, {16, 1}, {4, 100}}
+	for _, c := range cases {
+		b.Run(fmt.Sprintf("workers=%d/batch=%d", c.workers, c.batchSize), func(b *testing.B) {
+			var failed atomic.Int64
+			pool := newKeyedPool(c.workers, 256, c.batchSize, 10*time.Millisecond, func(batch []model.Order) {
+				if _, err := db.SaveOrders(context.Background(), batch); err != nil {
+					failed.Add(int64(len(batch)))
+				}
+			})
+			for i := 0; i < b.N; i++ {
+				order := template
+				order.OrderUID = fmt.Sprintf("%s-w%d-b%d-%d", run, c.workers, c.batchSize, i)
+				order.Items = append([]model.Item(nil), template.Items...)
+				pool.Submit(context.Background(), order.OrderUID, order)
+			}
+			pool.Close()
+			if n := failed.Load(); n > 0 {
+				b.Fatalf("%d orders failed to save", n)
+			}
+		})
+	}
+
+	if err := db.Exec(context.Background(), "DELETE FROM outbox WHERE aggregate_id LIKE $1", run+"-%"); err != nil {
+		b.Logf("outbox cleanup failed: %v", err)
+	}
+	if err := db.Exec(context.Background(), "DELETE FROM orders WHERE order_uid LIKE $1", run+"-%"); err != nil {
+		b.Logf("orders cleanup failed: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"order-service/internal/model"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Rule проверяет заказ и возвращает все найденные нарушения
+type Rule func(order *model.Order) []FieldError
+
+type Validator struct {
+	rules []Rule
+}
+
+func New(rules ...Rule) *Validator {
+	return &Validator{rules: rules}
+}
+
+func (v *Validator) Validate(order *model.Order) []FieldError {
+	if order == nil {
+		return []FieldError{{Field: "", Message: "order is required"}}
+	}
+
+	var errs []FieldError
+	for _, rule := range v.rules {
+		errs = append(errs, rule(order)...)
+	}
+	return errs
+}
+
+var DefaultCurrencies = []string{"RUB", "USD", "EUR", "KZT", "BYN", "CNY"}
+
+func DefaultRules() []Rule {
+	return []Rule{
+		RequiredFields,
+		NonNegativeVersion,
+		NonNegativeAmounts,
+		ItemTrackNumbers,
+		GoodsTotal,
+		DeliveryContacts,
+		Currency(DefaultCurrencies...),
+	}
+}
+
+var defaultValidator = New(DefaultRules()...)
+
+func Validate(order *model.Order) []FieldError {
+	return defaultValidator.Validate(order)
+}
+
+func RequiredFields(order *model.Order) []FieldError {
+	var errs []FieldError
+	required := []struct {
+		field string
+		value string
+	}{
+		{"order_uid", order.OrderUID},
+		{"track_number", order.TrackNumber},
+		{"customer_id", order.CustomerID},
+		{"payment.transaction", order.Payment.Transaction},
+		{"payment.currency", order.Payment.Currency},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			errs = append(errs, FieldError{Field: r.field, Message: "is required"})
+		}
+	}
+	if order.DateCreated.IsZero() {
+		errs = append(errs, FieldError{Field: "date_created", Message: "is required"})
+	}
+	if len(order.Items) == 0 {
+		errs = append(errs, FieldError{Field: "items", Message: "must contain at least one item"})
+	}
+	return errs
+}
+
+// NonNegativeVersion пропускает заказы без версии (0): их SaveOrder применяет
+// в порядке чтения из Kafka
+func NonNegativeVersion(order *model.Order) []FieldError {
+	if order.Version < 0 {
+		return []FieldError{{Field: "version", Message: "must not be negative"}}
+	}
+	return nil
+}
+
+// PositiveVersion требует явную версию: по ней SaveOrder отбрасывает
+// устаревшие и повторные сообщения. Включается processing.require_version
+func PositiveVersion(order *model.Order) []FieldError {
+	if order.Version <= 0 {
+		return []FieldError{{Field: "version", Message: "is required and must be positive"}}
+	}
+	return nil
+}
+
+func NonNegativeAmounts(order *model.Order) []FieldError {
+	var errs []FieldError
+	check := func(field string, value int64) {
+		if value < 0 {
+			errs = append(errs, FieldError{Field: field, Message: "must not be negative"})
+		}
+	}
+
+	check("payment.amount", order.Payment.Amount)
+	check("payment.delivery_cost", order.Payment.DeliveryCost)
+	check("payment.goods_total", order.Payment.GoodsTotal)
+	check("payment.custom_fee", order.Payment.CustomFee)
+	for i, item := range order.Items {
+		check(fmt.Sprintf("items[%d].price", i), item.Price)
+		check(fmt.Sprintf("items[%d].sale", i), int64(item.Sale))
+		check(fmt.Sprintf("items[%d].total_price", i), item.TotalPrice)
+	}
+	return errs
+}
+
+func ItemTrackNumbers(order *model.Order) []FieldError {
+	var errs []FieldError
+	for i, item := range order.Items {
+		if item.TrackNumber != order.TrackNumber {
+			errs = append(errs, FieldError{
+				Field:   fmt.Sprintf("items[%d].track_number", i),
+				Message: fmt.Sprintf("must match order track_number %q", order.TrackNumber),
+			})
+		}
+	}
+	return errs
+}
+
+func GoodsTotal(order *model.Order) []FieldError {
+	var sum int64
+	for _, item := range order.Items {
+		sum += item.TotalPrice
+	}
+	if sum != order.Payment.GoodsTotal {
+		return []FieldError{{
+			Field:   "payment.goods_total",
+			Message: fmt.Sprintf("must equal sum of items total_price (%d)", sum),
+		}}
+	}
+	return nil
+}
+
+var phoneRe = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+
+func DeliveryContacts(order *model.Order) []FieldError {
+	var errs []FieldError
+	if phone := order.Delivery.Phone; phone == "" || !phoneRe.MatchString(phone) {
+		errs = append(errs, FieldError{Field: "delivery.phone", Message: "must be a phone number in international format"})
+	}
+	if email := order.Delivery.Email; email != "" {
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			errs = append(errs, FieldError{Field: "delivery.email", Message: "must be a valid email address"})
+		}
+	}
+	return errs
+}
+
+func Currency(allowed ...string) Rule {
+	set := make(map[string]struct{}, len(allowed))
+	for _, c := range allowed {
+		set[strings.ToUpper(c)] = struct{}{}
+	}
+	return func(order *model.Order) []FieldError {
+		if order.Payment.Currency == "" {
+			return nil
+		}
+		if _, ok := set[strings.ToUpper(order.Payment.Currency)]; !ok {
+			return []FieldError{{Field: "payment.currency", Message: fmt.Sprintf("unknown currency %q", order.Payment.Currency)}}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"order-service/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validOrder() model.Order {
+	return model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		CustomerID:  "test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Version:     1,
+		Delivery: model.Delivery{
+			Phone: "+9720000000",
+			Email: "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Amount:       1817,
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []model.Item{
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Sale: 30, TotalPrice: 317},
+		},
+	}
+}
+
+func fields(errs []FieldError) []string {
+	var names []string
+	for _, e := range errs {
+		names = append(names, e.Field)
+	}
+	return names
+}
+
+func TestValidateDefaultRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *model.Order)
+		want   []string
+	}{
+		{"valid order", func(o *model.Order) {}, nil},
+		{"missing fields", func(o *model.Order) {
+			o.OrderUID = ""
+			o.CustomerID = "  "
+			o.Payment.Transaction = ""
+			o.DateCreated = time.Time{}
+		}, []string{"order_uid", "customer_id", "payment.transaction", "date_created"}},
+		{"no items", func(o *model.Order) {
+			o.Items = nil
+			o.Payment.GoodsTotal = 0
+		}, []string{"items"}},
+		{"missing version is allowed", func(o *model.Order) { o.Version = 0 }, nil},
+		{"negative version", func(o *model.Order) { o.Version = -1 }, []string{"version"}},
+		{"negative amounts", func(o *model.Order) {
+			o.Payment.Amount = -1
+			o.Payment.CustomFee = -5
+			o.Items[0].Price = -453
+		}, []string{"payment.amount", "payment.custom_fee", "items[0].price"}},
+		{"negative item total", func(o *model.Order) {
+			o.Items[0].TotalPrice = -317
+			o.Payment.GoodsTotal = -317
+		}, []string{"payment.goods_total", "items[0].total_price"}},
+		{"goods total mismatch", func(o *model.Order) { o.Payment.GoodsTotal = 300 }, []string{"payment.goods_total"}},
+		{"goods total of several items", func(o *model.Order) {
+			o.Items = append(o.Items, model.Item{TrackNumber: "WBILMTESTTRACK", TotalPrice: 100})
+			o.Payment.GoodsTotal = 417
+		}, nil},
+		{"item track number", func(o *model.Order) {
+			o.Items = append(o.Items, model.Item{TrackNumber: "OTHER"})
+		}, []string{"items[1].track_number"}},
+		{"bad phone", func(o *model.Order) { o.Delivery.Phone = "call me" }, []string{"delivery.phone"}},
+		{"bad email", func(o *model.Order) { o.Delivery.Email = "Test <test@gmail.com>" }, []string{"delivery.email"}},
+		{"empty email is allowed", func(o *model.Order) { o.Delivery.Email = "" }, nil},
+		{"unknown currency", func(o *model.Order) { o.Payment.Currency = "XYZ" }, []string{"payment.currency"}},
+		{"lowercase currency", func(o *model.Order) { o.Payment.Currency = "usd" }, nil},
+		{"missing currency", func(o *model.Order) { o.Payment.Currency = "" }, []string{"payment.currency"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(&order)
+			assert.ElementsMatch(t, tt.want, fields(Validate(&order)))
+		})
+	}
+}
+
+func TestValidateNilOrder(t *testing.T) {
+	errs := Validate(nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "order is required", errs[0].Message)
+}
+
+func TestCurrencyRule(t *testing.T) {
+	rule := Currency("rub", "Usd")
+	for _, currency := range []string{"RUB", "rub", "USD", "uSd"} {
+		order := model.Order{Payment: model.Payment{Currency: currency}}
+		assert.Empty(t, rule(&order), currency)
+	}
+
+	order := model.Order{Payment: model.Payment{Currency: "EUR"}}
+	errs := rule(&order)
+	require.Len(t, errs, 1)
+	assert.Equal(t, `unknown currency "EUR"`, errs[0].Message)
+}
+
+func TestPositiveVersion(t *testing.T) {
+	order := validOrder()
+	assert.Empty(t, PositiveVersion(&order))
+
+	for _, version := range []int64{0, -1} {
+		order.Version = version
+		assert.Equal(t, []string{"version"}, fields(PositiveVersion(&order)), "version %d", version)
+	}
+}
+
+func TestErrorsMessage(t *testing.T) {
+	err := Errors{
+		{Field: "order_uid", Message: "is required"},
+		{Field: "payment.goods_total", Message: "must equal sum of items total_price (317)"},
+	}
+	assert.Equal(t, "order_uid: is required; payment.goods_total: must equal sum of items total_price (317)", err.Error())
+}
+
+func TestCustomRules(t *testing.T) {
+	order := validOrder()
+	order.Items = nil
+	assert.Equal(t, []string{"items"}, fields(New(RequiredFields).Validate(&order)))
+	assert.Empty(t, New().Validate(&order))
+}
@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders(date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id, date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders(track_number);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_service ON orders(delivery_service, date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_locale ON orders(locale, date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_items_brand ON items(brand, order_uid);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items(nm_id, order_uid);
@@ -0,0 +1,27 @@
+-- NOT NULL не снимаем: прежняя схема и код с ними совместимы
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_order_uid ON deliveries(order_uid);
+CREATE INDEX IF NOT EXISTS idx_payments_order_uid ON payments(order_uid);
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items(order_uid);
+
+ALTER TABLE items
+    DROP CONSTRAINT IF EXISTS items_amounts_non_negative,
+    DROP CONSTRAINT IF EXISTS items_order_uid_chrt_id_key,
+    ALTER COLUMN total_price TYPE INTEGER,
+    ALTER COLUMN price TYPE INTEGER;
+
+ALTER TABLE payments
+    DROP CONSTRAINT IF EXISTS payments_amounts_non_negative,
+    DROP CONSTRAINT IF EXISTS payments_order_uid_key,
+    ALTER COLUMN custom_fee TYPE INTEGER,
+    ALTER COLUMN goods_total TYPE INTEGER,
+    ALTER COLUMN delivery_cost TYPE INTEGER,
+    ALTER COLUMN amount TYPE INTEGER;
+
+ALTER TABLE deliveries
+    DROP CONSTRAINT IF EXISTS deliveries_order_uid_key;
+
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS orders_status_valid,
+    DROP CONSTRAINT IF EXISTS orders_version_non_negative,
+    DROP CONSTRAINT IF EXISTS orders_order_uid_not_empty;
@@ -0,0 +1,127 @@
+-- Одна доставка и одна оплата на заказ: оставляем последние записанные строки
+DELETE FROM deliveries d USING deliveries newer
+WHERE d.order_uid = newer.order_uid AND d.id < newer.id;
+
+DELETE FROM payments p USING payments newer
+WHERE p.order_uid = newer.order_uid AND p.id < newer.id;
+
+DELETE FROM items i USING items newer
+WHERE i.order_uid = newer.order_uid AND i.chrt_id = newer.chrt_id AND i.id < newer.id;
+
+DELETE FROM deliveries WHERE order_uid IS NULL;
+DELETE FROM payments WHERE order_uid IS NULL;
+DELETE FROM items WHERE order_uid IS NULL OR chrt_id IS NULL;
+
+UPDATE orders SET
+    track_number = COALESCE(track_number, ''),
+    entry = COALESCE(entry, ''),
+    locale = COALESCE(locale, ''),
+    internal_signature = COALESCE(internal_signature, ''),
+    customer_id = COALESCE(customer_id, ''),
+    delivery_service = COALESCE(delivery_service, ''),
+    shardkey = COALESCE(shardkey, ''),
+    sm_id = COALESCE(sm_id, 0),
+    date_created = COALESCE(date_created, 'epoch'),
+    oof_shard = COALESCE(oof_shard, '');
+
+UPDATE deliveries SET
+    name = COALESCE(name, ''),
+    phone = COALESCE(phone, ''),
+    zip = COALESCE(zip, ''),
+    city = COALESCE(city, ''),
+    address = COALESCE(address, ''),
+    region = COALESCE(region, ''),
+    email = COALESCE(email, '');
+
+UPDATE payments SET
+    transaction = COALESCE(transaction, ''),
+    request_id = COALESCE(request_id, ''),
+    currency = COALESCE(currency, ''),
+    provider = COALESCE(provider, ''),
+    amount = COALESCE(amount, 0),
+    payment_dt = COALESCE(payment_dt, 0),
+    bank = COALESCE(bank, ''),
+    delivery_cost = COALESCE(delivery_cost, 0),
+    goods_total = COALESCE(goods_total, 0),
+    custom_fee = COALESCE(custom_fee, 0);
+
+UPDATE items SET
+    track_number = COALESCE(track_number, ''),
+    price = COALESCE(price, 0),
+    rid = COALESCE(rid, ''),
+    name = COALESCE(name, ''),
+    sale = COALESCE(sale, 0),
+    size = COALESCE(size, ''),
+    total_price = COALESCE(total_price, 0),
+    nm_id = COALESCE(nm_id, 0),
+    brand = COALESCE(brand, ''),
+    status = COALESCE(status, 0);
+
+ALTER TABLE orders
+    ALTER COLUMN track_number SET NOT NULL,
+    ALTER COLUMN entry SET NOT NULL,
+    ALTER COLUMN locale SET NOT NULL,
+    ALTER COLUMN internal_signature SET NOT NULL,
+    ALTER COLUMN customer_id SET NOT NULL,
+    ALTER COLUMN delivery_service SET NOT NULL,
+    ALTER COLUMN shardkey SET NOT NULL,
+    ALTER COLUMN sm_id SET NOT NULL,
+    ALTER COLUMN date_created SET NOT NULL,
+    ALTER COLUMN oof_shard SET NOT NULL,
+    ADD CONSTRAINT orders_order_uid_not_empty CHECK (order_uid <> ''),
+    ADD CONSTRAINT orders_version_non_negative CHECK (version >= 0),
+    ADD CONSTRAINT orders_status_valid CHECK (status IN ('created', 'paid', 'shipped', 'delivered', 'cancelled'));
+
+ALTER TABLE deliveries
+    ALTER COLUMN order_uid SET NOT NULL,
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN phone SET NOT NULL,
+    ALTER COLUMN zip SET NOT NULL,
+    ALTER COLUMN city SET NOT NULL,
+    ALTER COLUMN address SET NOT NULL,
+    ALTER COLUMN region SET NOT NULL,
+    ALTER COLUMN email SET NOT NULL,
+    ADD CONSTRAINT deliveries_order_uid_key UNIQUE (order_uid);
+
+ALTER TABLE payments
+    ALTER COLUMN order_uid SET NOT NULL,
+    ALTER COLUMN transaction SET NOT NULL,
+    ALTER COLUMN request_id SET NOT NULL,
+    ALTER COLUMN currency SET NOT NULL,
+    ALTER COLUMN provider SET NOT NULL,
+    ALTER COLUMN amount TYPE BIGINT,
+    ALTER COLUMN amount SET NOT NULL,
+    ALTER COLUMN payment_dt SET NOT NULL,
+    ALTER COLUMN bank SET NOT NULL,
+    ALTER COLUMN delivery_cost TYPE BIGINT,
+    ALTER COLUMN delivery_cost SET NOT NULL,
+    ALTER COLUMN goods_total TYPE BIGINT,
+    ALTER COLUMN goods_total SET NOT NULL,
+    ALTER COLUMN custom_fee TYPE BIGINT,
+    ALTER COLUMN custom_fee SET NOT NULL,
+    ADD CONSTRAINT payments_order_uid_key UNIQUE (order_uid),
+    ADD CONSTRAINT payments_amounts_non_negative
+        CHECK (amount >= 0 AND delivery_cost >= 0 AND goods_total >= 0 AND custom_fee >= 0);
+
+ALTER TABLE items
+    ALTER COLUMN order_uid SET NOT NULL,
+    ALTER COLUMN chrt_id SET NOT NULL,
+    ALTER COLUMN track_number SET NOT NULL,
+    ALTER COLUMN price TYPE BIGINT,
+    ALTER COLUMN price SET NOT NULL,
+    ALTER COLUMN rid SET NOT NULL,
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN sale SET NOT NULL,
+    ALTER COLUMN size SET NOT NULL,
+    ALTER COLUMN total_price TYPE BIGINT,
+    ALTER COLUMN total_price SET NOT NULL,
+    ALTER COLUMN nm_id SET NOT NULL,
+    ALTER COLUMN brand SET NOT NULL,
+    ALTER COLUMN status SET NOT NULL,
+    ADD CONSTRAINT items_order_uid_chrt_id_key UNIQUE (order_uid, chrt_id),
+    ADD CONSTRAINT items_amounts_non_negative CHECK (price >= 0 AND sale >= 0 AND total_price >= 0);
+
+-- Уникальные индексы покрывают поиск по order_uid
+DROP INDEX IF EXISTS idx_deliveries_order_uid;
+DROP INDEX IF EXISTS idx_payments_order_uid;
+DROP INDEX IF EXISTS idx_items_order_uid;
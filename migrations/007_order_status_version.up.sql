@@ -0,0 +1,3 @@
+-- Увеличивается при каждой смене статуса; по паре (version, status_version)
+-- кэш отличает свежий снимок заказа от устаревшего
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status_version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+// Package migrations содержит SQL-миграции схемы; они встраиваются в бинарник
+// и применяются командой "order-service migrate" или при старте с DB_AUTO_MIGRATE
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS